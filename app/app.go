@@ -71,6 +71,7 @@ func (a *App) Run() error {
 	if a.opts.rpcServer != nil {
 		servers = append(servers, a.opts.rpcServer)
 	}
+	servers = append(servers, a.opts.servers...)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	a.cancel = cancel
@@ -120,10 +121,23 @@ func (a *App) Run() error {
 			return a.Stop()
 		}
 	})
-	if err := eg.Wait(); err != nil {
-		return err
+	err = eg.Wait()
+	a.afterStop()
+	return err
+}
+
+// afterStop 在所有的 server 停止之后执行
+func (a *App) afterStop() {
+	if len(a.opts.afterStop) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.stopTimeout)
+	defer cancel()
+	for _, fn := range a.opts.afterStop {
+		if err := fn(ctx); err != nil {
+			log.Errorf("after stop error: %s", err)
+		}
 	}
-	return nil
 }
 
 // Stop 停止服务
@@ -169,6 +183,14 @@ func (a *App) buildInstance() (*registry.ServiceInstance, error) {
 		}
 	}
 
+	if a.opts.restServer != nil {
+		u, err := a.opts.restServer.Endpoint()
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, u.String())
+	}
+
 	return &registry.ServiceInstance{
 		ID:        a.opts.id,
 		Name:      a.opts.name,
@@ -6,11 +6,13 @@ package app
  */
 
 import (
+	"context"
 	"net/url"
 	"os"
 	"time"
 
 	"github.com/chaos-ma/chaos/registry"
+	"github.com/chaos-ma/chaos/server"
 	"github.com/chaos-ma/chaos/server/httpserver"
 	"github.com/chaos-ma/chaos/server/rpcserver"
 )
@@ -33,6 +35,10 @@ type options struct {
 
 	restServer *httpserver.Server
 	rpcServer  *rpcserver.Server
+	//其他的server, 例如 admin server, 不注册到注册中心
+	servers []server.Server
+	//server 停止后执行, 例如 flush trace
+	afterStop []func(context.Context) error
 }
 
 func WithRegistrar(registrar registry.Registrar) Option {
@@ -59,6 +65,21 @@ func WithRestServer(server *httpserver.Server) Option {
 	}
 }
 
+// WithServers runs the extra servers with the app, e.g. the admin server, their endpoints are not registered.
+func WithServers(servers ...server.Server) Option {
+	return func(o *options) {
+		o.servers = append(o.servers, servers...)
+	}
+}
+
+// WithAfterStop runs fns after the servers stop, e.g. the shutdown returned by trace.InitAgent,
+// they share the stop timeout.
+func WithAfterStop(fns ...func(context.Context) error) Option {
+	return func(o *options) {
+		o.afterStop = append(o.afterStop, fns...)
+	}
+}
+
 func WithID(id string) Option {
 	return func(o *options) {
 		o.id = id
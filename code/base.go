@@ -52,6 +52,9 @@ const (
 
 	// ErrPermissionDenied - 403: Permission denied.
 	ErrPermissionDenied
+
+	// ErrTokenRevoked - 401: Token has been revoked.
+	ErrTokenRevoked
 )
 
 // common: encode/decode errors.
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
 )
 
 // ErrResponse defines the return messages when an error occurred.
@@ -24,6 +25,9 @@ type ErrResponse struct {
 
 	// Reference returns the reference document which maybe useful to solve this error.
 	Reference string `json:"reference,omitempty"`
+
+	// RequestID is the X-Request-ID of the request, it helps to find the logs of this error.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // WriteResponse write an error or the response data into http response body.
@@ -38,6 +42,7 @@ func WriteResponse(c *gin.Context, err error, data interface{}) {
 			Msg:       coder.String(),
 			Detail:    errStr,
 			Reference: coder.Reference(),
+			RequestID: c.GetString(log.KeyRequestID),
 		})
 
 		return
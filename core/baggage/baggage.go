@@ -0,0 +1,193 @@
+// Package baggage carries the request scoped values like the tenant, the user and the canary flag
+// through every hop by the W3C baggage header, on top of the otel baggage.
+package baggage
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc/metadata"
+)
+
+// The typed keys.
+const (
+	KeyTenant = "tenant_id"
+	KeyUser   = "user_id"
+	KeyCanary = "canary"
+)
+
+var (
+	mu sync.RWMutex
+	// 只有允许的 key 会被提取和传递, 避免任意的 header 泄漏到下游
+	allowed = map[string]bool{KeyTenant: true, KeyUser: true, KeyCanary: true}
+)
+
+// SetAllowedKeys replaces the allow-list, the members of other keys are dropped when
+// extracted from the requests and injected into the outgoing requests.
+func SetAllowedKeys(keys ...string) {
+	m := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		m[k] = true
+	}
+	mu.Lock()
+	allowed = m
+	mu.Unlock()
+}
+
+// AllowKeys adds keys to the allow-list.
+func AllowKeys(keys ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := make(map[string]bool, len(allowed)+len(keys))
+	for k := range allowed {
+		m[k] = true
+	}
+	for _, k := range keys {
+		m[k] = true
+	}
+	allowed = m
+}
+
+// Allowed reports whether key is in the allow-list.
+func Allowed(key string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return allowed[key]
+}
+
+// With returns a copy of ctx with key=value in the baggage, the key which is not allowed stays
+// in the process and is not propagated. The value is kept percent-encoded in the member,
+// otel rejects the whole header whose decoded values have spaces, commas, semicolons or non-ascii.
+func With(ctx context.Context, key, value string) (context.Context, error) {
+	m, err := baggage.NewMember(key, url.PathEscape(encode(value)))
+	if err != nil {
+		return ctx, err
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
+
+// Get returns the value of key in the baggage of ctx.
+func Get(ctx context.Context, key string) string {
+	return Value(baggage.FromContext(ctx).Member(key))
+}
+
+// Value returns the decoded value of the member set by With.
+func Value(m baggage.Member) string {
+	v, err := url.PathUnescape(m.Value())
+	if err != nil {
+		// 其他服务设置的未编码的值
+		return m.Value()
+	}
+	return v
+}
+
+// encode escapes all but the unreserved characters, so the value is valid after decoded once.
+func encode(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// WithTenant returns a copy of ctx with the tenant id.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	ctx, _ = With(ctx, KeyTenant, tenant)
+	return ctx
+}
+
+// Tenant returns the tenant id of ctx.
+func Tenant(ctx context.Context) string {
+	return Get(ctx, KeyTenant)
+}
+
+// WithUser returns a copy of ctx with the user id.
+func WithUser(ctx context.Context, user string) context.Context {
+	ctx, _ = With(ctx, KeyUser, user)
+	return ctx
+}
+
+// User returns the user id of ctx.
+func User(ctx context.Context) string {
+	return Get(ctx, KeyUser)
+}
+
+// WithCanary returns a copy of ctx with the canary flag.
+func WithCanary(ctx context.Context, canary bool) context.Context {
+	ctx, _ = With(ctx, KeyCanary, strconv.FormatBool(canary))
+	return ctx
+}
+
+// Canary reports whether the request is a canary request.
+func Canary(ctx context.Context) bool {
+	canary, _ := strconv.ParseBool(Get(ctx, KeyCanary))
+	return canary
+}
+
+// Filter drops the members which are not allowed.
+func Filter(b baggage.Baggage) baggage.Baggage {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, m := range b.Members() {
+		if !allowed[m.Key()] {
+			b = b.DeleteMember(m.Key())
+		}
+	}
+	return b
+}
+
+var propagator = propagation.Baggage{}
+
+// Extract extracts the allowed members from carrier into ctx, they replace the baggage of ctx,
+// e.g. the unfiltered one extracted by the tracing middleware.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	b := baggage.FromContext(propagator.Extract(context.Background(), carrier))
+	return baggage.ContextWithBaggage(ctx, Filter(b))
+}
+
+// Inject injects the allowed members of ctx into carrier, it replaces the baggage header set before,
+// e.g. the unfiltered one injected by the tracing interceptors.
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	b := Filter(baggage.FromContext(ctx))
+	propagator.Inject(baggage.ContextWithBaggage(ctx, b), carrier)
+}
+
+// ExtractMetadata is Extract of the grpc incoming metadata.
+func ExtractMetadata(ctx context.Context, md metadata.MD) context.Context {
+	return Extract(ctx, metadataCarrier(md))
+}
+
+// InjectMetadata is Inject of the grpc outgoing metadata, the baggage of md is removed if ctx has no allowed members.
+func InjectMetadata(ctx context.Context, md metadata.MD) {
+	md.Delete(headerKey)
+	Inject(ctx, metadataCarrier(md))
+}
+
+// headerKey is the W3C baggage header
+const headerKey = "baggage"
+
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
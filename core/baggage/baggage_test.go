@@ -0,0 +1,117 @@
+package baggage
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTypedRoundTrip(t *testing.T) {
+	tenant, user := "acme corp/東京,;=%", "alice@example.com"
+	ctx := WithCanary(WithUser(WithTenant(context.Background(), tenant), user), true)
+	if Tenant(ctx) != tenant || User(ctx) != user || !Canary(ctx) {
+		t.Fatalf("tenant = %q, user = %q, canary = %v", Tenant(ctx), User(ctx), Canary(ctx))
+	}
+
+	// 经过 header 传递后不变
+	header := http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	got := Extract(context.Background(), propagation.HeaderCarrier(header))
+	if Tenant(got) != tenant || User(got) != user || !Canary(got) {
+		t.Errorf("extracted tenant = %q, user = %q, canary = %v from %q",
+			Tenant(got), User(got), Canary(got), header.Get(headerKey))
+	}
+
+	if Canary(WithCanary(ctx, false)) {
+		t.Error("canary should be overwritten")
+	}
+	if Canary(context.Background()) || Tenant(context.Background()) != "" {
+		t.Error("empty context has no baggage")
+	}
+}
+
+func TestExtractDropsDisallowedKeys(t *testing.T) {
+	header := http.Header{}
+	header.Set(headerKey, "tenant_id=t1,session=secret,user_id=u1,canary=true")
+	ctx := Extract(context.Background(), propagation.HeaderCarrier(header))
+	if Tenant(ctx) != "t1" || User(ctx) != "u1" || !Canary(ctx) {
+		t.Errorf("allowed members are lost: tenant = %q, user = %q", Tenant(ctx), User(ctx))
+	}
+	if Get(ctx, "session") != "" {
+		t.Error("session should be dropped")
+	}
+}
+
+func TestInjectDropsDisallowedKeys(t *testing.T) {
+	ctx, err := With(WithTenant(context.Background(), "t1"), "session", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不允许的 key 只在进程内可见
+	if Get(ctx, "session") != "secret" {
+		t.Fatal("session should be kept in the process")
+	}
+	header := http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	if got := header.Get(headerKey); got != "tenant_id=t1" {
+		t.Errorf("baggage = %q, want tenant_id=t1", got)
+	}
+}
+
+func TestInjectMetadataReplacesUnfilteredBaggage(t *testing.T) {
+	ctx, err := With(WithTenant(context.Background(), "t1"), "session", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tracing 拦截器注入的未过滤的 baggage
+	md := metadata.Pairs(headerKey, "tenant_id=t1,session=secret", "x-request-id", "r1")
+	InjectMetadata(ctx, md)
+	if got := md.Get(headerKey); len(got) != 1 || got[0] != "tenant_id=t1" {
+		t.Errorf("baggage = %v, want only tenant_id=t1", got)
+	}
+	if md.Get("x-request-id")[0] != "r1" {
+		t.Error("other metadata should be kept")
+	}
+
+	// 没有允许的成员时删除 baggage
+	md = metadata.Pairs(headerKey, "session=secret")
+	ctx, _ = With(context.Background(), "session", "secret")
+	InjectMetadata(ctx, md)
+	if got := md.Get(headerKey); len(got) != 0 {
+		t.Errorf("baggage = %v, want none", got)
+	}
+
+	got := ExtractMetadata(context.Background(), metadata.Pairs(headerKey, "tenant_id=t2,session=secret"))
+	if Tenant(got) != "t2" || Get(got, "session") != "" {
+		t.Errorf("extracted tenant = %q, session = %q", Tenant(got), Get(got, "session"))
+	}
+}
+
+func TestAllowKeys(t *testing.T) {
+	t.Cleanup(func() { SetAllowedKeys(KeyTenant, KeyUser, KeyCanary) })
+
+	AllowKeys("region")
+	if !Allowed("region") || !Allowed(KeyTenant) {
+		t.Fatal("AllowKeys should add to the allow-list")
+	}
+	header := http.Header{}
+	header.Set(headerKey, "tenant_id=t1,region=eu")
+	ctx := Extract(context.Background(), propagation.HeaderCarrier(header))
+	if Get(ctx, "region") != "eu" {
+		t.Error("region should be extracted")
+	}
+
+	SetAllowedKeys("region")
+	if Allowed(KeyTenant) {
+		t.Error("SetAllowedKeys should replace the allow-list")
+	}
+	header = http.Header{}
+	Inject(ctx, propagation.HeaderCarrier(header))
+	if got := header.Get(headerKey); !strings.Contains(got, "region=eu") || strings.Contains(got, "tenant_id") {
+		t.Errorf("baggage = %q, want only region", got)
+	}
+}
@@ -25,24 +25,9 @@ type (
 	}
 )
 
-// NewCounterVec returns a CounterVec.
+// NewCounterVec returns a CounterVec of the default registry.
 func NewCounterVec(cfg *CounterVecOpts) CounterVec {
-	if cfg == nil {
-		return nil
-	}
-
-	vec := prom.NewCounterVec(prom.CounterOpts{
-		Namespace: cfg.Namespace,
-		Subsystem: cfg.Subsystem,
-		Name:      cfg.Name,
-		Help:      cfg.Help,
-	}, cfg.Labels)
-	prom.MustRegister(vec)
-	cv := &promCounterVec{
-		counter: vec,
-	}
-
-	return cv
+	return DefaultRegistry().NewCounterVec(cfg)
 }
 
 func (cv *promCounterVec) Inc(labels ...string) {
@@ -0,0 +1,24 @@
+package metric
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exemplarLabels returns the trace id and span id of the sampled span in ctx,
+// the exemplars are only exposed in the OpenMetrics format.
+func exemplarLabels(ctx context.Context) prom.Labels {
+	if ctx == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return prom.Labels{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+}
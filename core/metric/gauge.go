@@ -27,25 +27,9 @@ type (
 	}
 )
 
-// NewGaugeVec returns a GaugeVec.
+// NewGaugeVec returns a GaugeVec of the default registry.
 func NewGaugeVec(cfg *GaugeVecOpts) GaugeVec {
-	if cfg == nil {
-		return nil
-	}
-
-	vec := prom.NewGaugeVec(
-		prom.GaugeOpts{
-			Namespace: cfg.Namespace,
-			Subsystem: cfg.Subsystem,
-			Name:      cfg.Name,
-			Help:      cfg.Help,
-		}, cfg.Labels)
-	prom.MustRegister(vec)
-	gv := &promGaugeVec{
-		gauge: vec,
-	}
-
-	return gv
+	return DefaultRegistry().NewGaugeVec(cfg)
 }
 
 func (gv *promGaugeVec) Inc(labels ...string) {
@@ -5,6 +5,8 @@ package metric
  */
 
 import (
+	"context"
+
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
@@ -17,12 +19,21 @@ type (
 		Help      string
 		Labels    []string
 		Buckets   []float64
+		// NativeBucketFactor enables the native histogram if greater than 1, e.g. 1.1 means
+		// every bucket is at most 10% wider than the former one, it works together with Buckets
+		NativeBucketFactor float64
+		// NativeMaxBuckets limits the buckets of the native histogram, 160 by default
+		NativeMaxBuckets uint32
 	}
 
 	// A HistogramVec interface represents a histogram vector.
 	HistogramVec interface {
 		// Observe adds observation v to labels.
 		Observe(v int64, labels ...string)
+		// ObserveFloat adds observation v to labels.
+		ObserveFloat(v float64, labels ...string)
+		// ObserveContext adds observation v to labels, with the trace id of ctx as the exemplar.
+		ObserveContext(ctx context.Context, v float64, labels ...string)
 	}
 
 	promHistogramVec struct {
@@ -30,27 +41,26 @@ type (
 	}
 )
 
-// NewHistogramVec returns a HistogramVec.
+// NewHistogramVec returns a HistogramVec of the default registry.
 func NewHistogramVec(cfg *HistogramVecOpts) HistogramVec {
-	if cfg == nil {
-		return nil
-	}
-
-	vec := prom.NewHistogramVec(prom.HistogramOpts{
-		Namespace: cfg.Namespace,
-		Subsystem: cfg.Subsystem,
-		Name:      cfg.Name,
-		Help:      cfg.Help,
-		Buckets:   cfg.Buckets,
-	}, cfg.Labels)
-	prom.MustRegister(vec)
-	hv := &promHistogramVec{
-		histogram: vec,
-	}
-
-	return hv
+	return DefaultRegistry().NewHistogramVec(cfg)
 }
 
 func (hv *promHistogramVec) Observe(v int64, labels ...string) {
 	hv.histogram.WithLabelValues(labels...).Observe(float64(v))
 }
+
+func (hv *promHistogramVec) ObserveFloat(v float64, labels ...string) {
+	hv.histogram.WithLabelValues(labels...).Observe(v)
+}
+
+func (hv *promHistogramVec) ObserveContext(ctx context.Context, v float64, labels ...string) {
+	observer := hv.histogram.WithLabelValues(labels...)
+	if exemplar := exemplarLabels(ctx); exemplar != nil {
+		if eo, ok := observer.(prom.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, exemplar)
+			return
+		}
+	}
+	observer.Observe(v)
+}
@@ -0,0 +1,86 @@
+package metric
+
+import (
+	"context"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestHistogramObserveContextExemplar(t *testing.T) {
+	reg := prom.NewRegistry()
+	hv := NewRegistry(WithPrometheus(reg)).NewHistogramVec(&HistogramVecOpts{
+		Name: "latency_ms", Labels: []string{"method"}, Buckets: []float64{10, 100},
+	})
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	defer tp.Shutdown(context.Background())
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	hv.ObserveContext(ctx, 5, "GET")
+	// 没有 span 时不带 exemplar
+	hv.ObserveContext(context.Background(), 50, "GET")
+
+	h := gather(t, reg, "latency_ms").GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 2 {
+		t.Fatalf("count = %d, want 2", h.GetSampleCount())
+	}
+	buckets := h.GetBucket()
+	e := buckets[0].GetExemplar()
+	if e == nil {
+		t.Fatal("no exemplar on the first bucket")
+	}
+	labels := map[string]string{}
+	for _, l := range e.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["trace_id"] != span.SpanContext().TraceID().String() || labels["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("exemplar labels = %v", labels)
+	}
+	if buckets[1].GetExemplar() != nil {
+		t.Error("the observation without a span has no exemplar")
+	}
+}
+
+func TestNativeHistogram(t *testing.T) {
+	reg := prom.NewRegistry()
+	r := NewRegistry(WithPrometheus(reg))
+	native := r.NewHistogramVec(&HistogramVecOpts{Name: "native_ms", Buckets: []float64{10, 100}, NativeBucketFactor: 1.1})
+	classic := r.NewHistogramVec(&HistogramVecOpts{Name: "classic_ms", Buckets: []float64{10, 100}})
+	for _, v := range []float64{1, 5, 50, 500} {
+		native.ObserveFloat(v)
+		classic.ObserveFloat(v)
+	}
+
+	h := gather(t, reg, "native_ms").GetMetric()[0].GetHistogram()
+	// factor 1.1 对应 schema 3, 每个 bucket 最多比前一个宽 2^(1/8)
+	if h.Schema == nil || h.GetSchema() != 3 {
+		t.Errorf("schema = %v, want 3", h.Schema)
+	}
+	if len(h.GetPositiveSpan()) == 0 || len(h.GetPositiveDelta()) == 0 {
+		t.Error("no native buckets")
+	}
+	// 同时保留经典的 buckets
+	if len(h.GetBucket()) != 2 || h.GetBucket()[1].GetCumulativeCount() != 3 {
+		t.Errorf("classic buckets = %v", h.GetBucket())
+	}
+
+	if h := gather(t, reg, "classic_ms").GetMetric()[0].GetHistogram(); h.Schema != nil || len(h.GetPositiveSpan()) != 0 {
+		t.Errorf("the classic histogram has native buckets: schema %v", h.Schema)
+	}
+
+	tests := []struct {
+		opts HistogramVecOpts
+		want uint32
+	}{
+		{HistogramVecOpts{NativeBucketFactor: 1.1}, 160},
+		{HistogramVecOpts{NativeBucketFactor: 1.1, NativeMaxBuckets: 20}, 20},
+		{HistogramVecOpts{}, 0},
+	}
+	for _, tt := range tests {
+		if got := nativeMaxBuckets(&tt.opts); got != tt.want {
+			t.Errorf("nativeMaxBuckets(%+v) = %d, want %d", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// OTLP protocols.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// OTelOption is otel registry option.
+type OTelOption func(o *otelOptions)
+
+type otelOptions struct {
+	endpoint    string
+	protocol    string
+	insecure    bool
+	headers     map[string]string
+	interval    time.Duration
+	res         *resource.Resource
+	constLabels map[string]string
+	reader      sdkmetric.Reader
+}
+
+// WithOTLPEndpoint sets the collector address, e.g. "otel-collector:4317", defaults to the
+// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost.
+func WithOTLPEndpoint(endpoint string) OTelOption {
+	return func(o *otelOptions) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithOTLPProtocol sets the protocol, ProtocolGRPC by default.
+func WithOTLPProtocol(protocol string) OTelOption {
+	return func(o *otelOptions) {
+		o.protocol = protocol
+	}
+}
+
+// WithOTLPInsecure exports without tls.
+func WithOTLPInsecure(insecure bool) OTelOption {
+	return func(o *otelOptions) {
+		o.insecure = insecure
+	}
+}
+
+// WithOTLPHeaders sets the headers of the export requests, e.g. the auth token of the collector.
+func WithOTLPHeaders(headers map[string]string) OTelOption {
+	return func(o *otelOptions) {
+		o.headers = headers
+	}
+}
+
+// WithExportInterval sets the interval of the periodic export, 15s by default.
+func WithExportInterval(interval time.Duration) OTelOption {
+	return func(o *otelOptions) {
+		o.interval = interval
+	}
+}
+
+// WithOTelResource sets the resource, e.g. service.name, of the exported metrics.
+func WithOTelResource(res *resource.Resource) OTelOption {
+	return func(o *otelOptions) {
+		o.res = res
+	}
+}
+
+// WithOTelConstLabels adds the attributes to every data point, like WithConstLabels.
+func WithOTelConstLabels(labels map[string]string) OTelOption {
+	return func(o *otelOptions) {
+		o.constLabels = labels
+	}
+}
+
+// WithOTelReader reads the metrics by reader instead of the OTLP exporter, e.g. sdkmetric.NewManualReader() in tests.
+func WithOTelReader(reader sdkmetric.Reader) OTelOption {
+	return func(o *otelOptions) {
+		o.reader = reader
+	}
+}
+
+// OTelRegistry is the Registry backed by the otel metric SDK, the metrics are exported by OTLP.
+// Summaries are exported as exponential histograms, the quantiles are calculated by the backend.
+// It is push only, Handler does not serve the metrics.
+type OTelRegistry struct {
+	provider    *sdkmetric.MeterProvider
+	meter       otelmetric.Meter
+	constLabels []attribute.KeyValue
+
+	mu          sync.Mutex
+	instruments map[string]interface{}
+
+	// histograms 保存直方图的聚合配置, 由 view 在创建 instrument 时读取
+	aggMu      sync.RWMutex
+	histograms map[string]sdkmetric.Aggregation
+}
+
+var _ Registry = (*OTelRegistry)(nil)
+
+// NewOTelRegistry creates a registry exporting by OTLP, call Shutdown to flush the metrics on exit.
+func NewOTelRegistry(ctx context.Context, opts ...OTelOption) (*OTelRegistry, error) {
+	o := otelOptions{
+		protocol: ProtocolGRPC,
+		interval: 15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	reader := o.reader
+	if reader == nil {
+		exporter, err := newOTLPExporter(ctx, &o)
+		if err != nil {
+			return nil, err
+		}
+		reader = sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(o.interval))
+	}
+
+	r := &OTelRegistry{
+		instruments: make(map[string]interface{}),
+		histograms:  make(map[string]sdkmetric.Aggregation),
+	}
+	keys := make([]string, 0, len(o.constLabels))
+	for k := range o.constLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.constLabels = append(r.constLabels, attribute.String(k, o.constLabels[k]))
+	}
+
+	providerOpts := []sdkmetric.Option{sdkmetric.WithReader(reader), sdkmetric.WithView(r.view)}
+	if o.res != nil {
+		providerOpts = append(providerOpts, sdkmetric.WithResource(o.res))
+	}
+	r.provider = sdkmetric.NewMeterProvider(providerOpts...)
+	r.meter = r.provider.Meter("github.com/chaos-ma/chaos/core/metric")
+	return r, nil
+}
+
+func newOTLPExporter(ctx context.Context, o *otelOptions) (sdkmetric.Exporter, error) {
+	switch o.protocol {
+	case ProtocolGRPC:
+		var opts []otlpmetricgrpc.Option
+		if o.endpoint != "" {
+			opts = append(opts, otlpmetricgrpc.WithEndpoint(o.endpoint))
+		}
+		if o.insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		if len(o.headers) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(o.headers))
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	case ProtocolHTTP:
+		var opts []otlpmetrichttp.Option
+		if o.endpoint != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(o.endpoint))
+		}
+		if o.insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(o.headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(o.headers))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	default:
+		return nil, errors.Errorf("unsupported otlp protocol: %s", o.protocol)
+	}
+}
+
+// view applies the buckets of the histograms, the other instruments keep the default aggregation.
+func (r *OTelRegistry) view(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+	r.aggMu.RLock()
+	agg, ok := r.histograms[inst.Name]
+	r.aggMu.RUnlock()
+	if !ok {
+		return sdkmetric.Stream{}, false
+	}
+	return sdkmetric.Stream{Name: inst.Name, Description: inst.Description, Unit: inst.Unit, Aggregation: agg}, true
+}
+
+func (r *OTelRegistry) NewCounterVec(cfg *CounterVecOpts) CounterVec {
+	if cfg == nil {
+		return nil
+	}
+	name := otelName(cfg.Namespace, cfg.Subsystem, cfg.Name)
+	inst := r.instrument(name, func() interface{} {
+		counter, err := r.meter.Float64Counter(name, otelmetric.WithDescription(cfg.Help))
+		if err != nil {
+			panic(err)
+		}
+		return &otelCounterVec{counter: counter, labels: r.labelSet(cfg.Labels)}
+	})
+	cv, ok := inst.(*otelCounterVec)
+	if !ok {
+		panic(fmt.Sprintf("metric: %s is registered as %T", name, inst))
+	}
+	return cv
+}
+
+func (r *OTelRegistry) NewGaugeVec(cfg *GaugeVecOpts) GaugeVec {
+	if cfg == nil {
+		return nil
+	}
+	name := otelName(cfg.Namespace, cfg.Subsystem, cfg.Name)
+	inst := r.instrument(name, func() interface{} {
+		g := &otelGaugeVec{labels: r.labelSet(cfg.Labels), values: make(map[attribute.Distinct]*gaugeValue)}
+		_, err := r.meter.Float64ObservableGauge(name, otelmetric.WithDescription(cfg.Help),
+			otelmetric.WithFloat64Callback(g.observe))
+		if err != nil {
+			panic(err)
+		}
+		return g
+	})
+	gv, ok := inst.(*otelGaugeVec)
+	if !ok {
+		panic(fmt.Sprintf("metric: %s is registered as %T", name, inst))
+	}
+	return gv
+}
+
+func (r *OTelRegistry) NewHistogramVec(cfg *HistogramVecOpts) HistogramVec {
+	if cfg == nil {
+		return nil
+	}
+	name := otelName(cfg.Namespace, cfg.Subsystem, cfg.Name)
+	var agg sdkmetric.Aggregation
+	switch {
+	case cfg.NativeBucketFactor > 1:
+		agg = sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: int32(nativeMaxBuckets(cfg)), MaxScale: 20}
+	case len(cfg.Buckets) > 0:
+		agg = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: cfg.Buckets}
+	}
+	return r.histogram(name, cfg.Help, cfg.Labels, agg)
+}
+
+func (r *OTelRegistry) NewSummaryVec(cfg *SummaryVecOpts) SummaryVec {
+	if cfg == nil {
+		return nil
+	}
+	name := otelName(cfg.Namespace, cfg.Subsystem, cfg.Name)
+	agg := sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+	return otelSummaryVec{r.histogram(name, cfg.Help, cfg.Labels, agg)}
+}
+
+func (r *OTelRegistry) histogram(name, help string, labels []string, agg sdkmetric.Aggregation) *otelHistogramVec {
+	inst := r.instrument(name, func() interface{} {
+		if agg != nil {
+			r.aggMu.Lock()
+			r.histograms[name] = agg
+			r.aggMu.Unlock()
+		}
+		histogram, err := r.meter.Float64Histogram(name, otelmetric.WithDescription(help))
+		if err != nil {
+			panic(err)
+		}
+		return &otelHistogramVec{histogram: histogram, labels: r.labelSet(labels)}
+	})
+	hv, ok := inst.(*otelHistogramVec)
+	if !ok {
+		panic(fmt.Sprintf("metric: %s is registered as %T", name, inst))
+	}
+	return hv
+}
+
+// Handler returns 404, the metrics are pushed to the collector, so /metrics of the servers serves nothing
+// with an OTelRegistry, keep a prometheus Registry for them if the metrics are also scraped.
+func (r *OTelRegistry) Handler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+// ForceFlush exports the metrics immediately.
+func (r *OTelRegistry) ForceFlush(ctx context.Context) error {
+	return r.provider.ForceFlush(ctx)
+}
+
+// Shutdown flushes the metrics and stops the export.
+func (r *OTelRegistry) Shutdown(ctx context.Context) error {
+	return r.provider.Shutdown(ctx)
+}
+
+// instrument returns the created instrument of the name, or creates a new one.
+func (r *OTelRegistry) instrument(name string, create func() interface{}) interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.instruments[name]; ok {
+		return existing
+	}
+	inst := create()
+	r.instruments[name] = inst
+	return inst
+}
+
+func (r *OTelRegistry) labelSet(names []string) *labelSet {
+	return &labelSet{names: names, constLabels: r.constLabels}
+}
+
+// otelName builds the name like prometheus, the otel collector exports it to prometheus unchanged.
+func otelName(namespace, subsystem, name string) string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{namespace, subsystem, name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+type labelSet struct {
+	names       []string
+	constLabels []attribute.KeyValue
+}
+
+func (l *labelSet) attributes(values []string) attribute.Set {
+	if len(values) != len(l.names) {
+		panic(fmt.Sprintf("metric: expected %d label values but got %d", len(l.names), len(values)))
+	}
+	kvs := make([]attribute.KeyValue, 0, len(l.names)+len(l.constLabels))
+	kvs = append(kvs, l.constLabels...)
+	for i, name := range l.names {
+		kvs = append(kvs, attribute.String(name, values[i]))
+	}
+	return attribute.NewSet(kvs...)
+}
+
+type otelCounterVec struct {
+	counter otelmetric.Float64Counter
+	labels  *labelSet
+}
+
+func (cv *otelCounterVec) Inc(labels ...string) {
+	cv.Add(1, labels...)
+}
+
+func (cv *otelCounterVec) Add(v float64, labels ...string) {
+	cv.counter.Add(context.Background(), v, otelmetric.WithAttributeSet(cv.labels.attributes(labels)))
+}
+
+type gaugeValue struct {
+	attrs attribute.Set
+	value float64
+}
+
+// otelGaugeVec keeps the last values, they are reported by the observable gauge on every collection.
+type otelGaugeVec struct {
+	labels *labelSet
+
+	mu     sync.Mutex
+	values map[attribute.Distinct]*gaugeValue
+}
+
+func (gv *otelGaugeVec) update(labels []string, fn func(v float64) float64) {
+	attrs := gv.labels.attributes(labels)
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	gauge, ok := gv.values[attrs.Equivalent()]
+	if !ok {
+		gauge = &gaugeValue{attrs: attrs}
+		gv.values[attrs.Equivalent()] = gauge
+	}
+	gauge.value = fn(gauge.value)
+}
+
+func (gv *otelGaugeVec) Set(v float64, labels ...string) {
+	gv.update(labels, func(float64) float64 { return v })
+}
+
+func (gv *otelGaugeVec) Inc(labels ...string) {
+	gv.Add(1, labels...)
+}
+
+func (gv *otelGaugeVec) Add(v float64, labels ...string) {
+	gv.update(labels, func(old float64) float64 { return old + v })
+}
+
+func (gv *otelGaugeVec) observe(_ context.Context, o otelmetric.Float64Observer) error {
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	for _, gauge := range gv.values {
+		o.Observe(gauge.value, otelmetric.WithAttributeSet(gauge.attrs))
+	}
+	return nil
+}
+
+type otelHistogramVec struct {
+	histogram otelmetric.Float64Histogram
+	labels    *labelSet
+}
+
+func (hv *otelHistogramVec) Observe(v int64, labels ...string) {
+	hv.ObserveContext(context.Background(), float64(v), labels...)
+}
+
+func (hv *otelHistogramVec) ObserveFloat(v float64, labels ...string) {
+	hv.ObserveContext(context.Background(), v, labels...)
+}
+
+// ObserveContext records v with ctx, the SDK samples the exemplars from the span in ctx if supported.
+func (hv *otelHistogramVec) ObserveContext(ctx context.Context, v float64, labels ...string) {
+	hv.histogram.Record(ctx, v, otelmetric.WithAttributeSet(hv.labels.attributes(labels)))
+}
+
+type otelSummaryVec struct {
+	histogram *otelHistogramVec
+}
+
+func (sv otelSummaryVec) Observe(v float64, labels ...string) {
+	sv.histogram.ObserveFloat(v, labels...)
+}
@@ -0,0 +1,182 @@
+package metric
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpReceiver is an in-process OTLP/HTTP collector keeping the received metrics.
+type otlpReceiver struct {
+	mu      sync.Mutex
+	metrics map[string]*metricspb.Metric
+}
+
+func (rc *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collectorpb.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc.mu.Lock()
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				rc.metrics[m.GetName()] = m
+			}
+		}
+	}
+	rc.mu.Unlock()
+
+	out, _ := proto.Marshal(&collectorpb.ExportMetricsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(out)
+}
+
+func (rc *otlpReceiver) metric(name string) *metricspb.Metric {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.metrics[name]
+}
+
+func attrs(kvs []*commonpb.KeyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	return m
+}
+
+func assertAttrs(t *testing.T, name string, got []*commonpb.KeyValue, want map[string]string) {
+	t.Helper()
+	m := attrs(got)
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s: attribute %s = %q, want %q", name, k, m[k], v)
+		}
+	}
+}
+
+func TestOTelRegistryExportsOverHTTP(t *testing.T) {
+	receiver := &otlpReceiver{metrics: make(map[string]*metricspb.Metric)}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	ctx := context.Background()
+	reg, err := NewOTelRegistry(ctx,
+		WithOTLPProtocol(ProtocolHTTP),
+		WithOTLPEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		WithOTLPInsecure(true),
+		WithOTelConstLabels(map[string]string{"service": "demo"}),
+	)
+	if err != nil {
+		t.Fatalf("NewOTelRegistry: %v", err)
+	}
+	defer reg.Shutdown(ctx)
+
+	counter := reg.NewCounterVec(&CounterVecOpts{Namespace: "test", Name: "requests_total", Labels: []string{"method"}})
+	counter.Inc("GET")
+	counter.Add(2, "GET")
+	gauge := reg.NewGaugeVec(&GaugeVecOpts{Namespace: "test", Name: "inflight", Labels: []string{"method"}})
+	gauge.Set(7, "GET")
+	histogram := reg.NewHistogramVec(&HistogramVecOpts{
+		Namespace: "test", Name: "duration_ms", Labels: []string{"method"}, Buckets: []float64{10, 100},
+	})
+	histogram.Observe(5, "GET")
+	histogram.Observe(50, "GET")
+
+	if err := reg.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
+	}
+	want := map[string]string{"service": "demo", "method": "GET"}
+
+	m := receiver.metric("test_requests_total")
+	if m == nil {
+		t.Fatal("counter not received")
+	}
+	points := m.GetSum().GetDataPoints()
+	if len(points) != 1 || points[0].GetAsDouble() != 3 {
+		t.Fatalf("counter data points = %v, want one point of 3", points)
+	}
+	assertAttrs(t, m.GetName(), points[0].GetAttributes(), want)
+
+	m = receiver.metric("test_inflight")
+	if m == nil {
+		t.Fatal("gauge not received")
+	}
+	gpoints := m.GetGauge().GetDataPoints()
+	if len(gpoints) != 1 || gpoints[0].GetAsDouble() != 7 {
+		t.Fatalf("gauge data points = %v, want one point of 7", gpoints)
+	}
+	assertAttrs(t, m.GetName(), gpoints[0].GetAttributes(), want)
+
+	m = receiver.metric("test_duration_ms")
+	if m == nil {
+		t.Fatal("histogram not received")
+	}
+	hpoints := m.GetHistogram().GetDataPoints()
+	if len(hpoints) != 1 {
+		t.Fatalf("histogram data points = %v, want one point", hpoints)
+	}
+	hp := hpoints[0]
+	assertAttrs(t, m.GetName(), hp.GetAttributes(), want)
+	if bounds := hp.GetExplicitBounds(); len(bounds) != 2 || bounds[0] != 10 || bounds[1] != 100 {
+		t.Errorf("histogram bounds = %v, want [10 100]", bounds)
+	}
+	if counts := hp.GetBucketCounts(); len(counts) != 3 || counts[0] != 1 || counts[1] != 1 || counts[2] != 0 {
+		t.Errorf("histogram bucket counts = %v, want [1 1 0]", counts)
+	}
+	if hp.GetCount() != 2 || hp.GetSum() != 55 {
+		t.Errorf("histogram count = %d sum = %v, want 2 and 55", hp.GetCount(), hp.GetSum())
+	}
+}
+
+func expectPanic(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: expected panic", name)
+		}
+	}()
+	fn()
+}
+
+func TestOTelRegistryPanics(t *testing.T) {
+	ctx := context.Background()
+	reg, err := NewOTelRegistry(ctx, WithOTelReader(sdkmetric.NewManualReader()))
+	if err != nil {
+		t.Fatalf("NewOTelRegistry: %v", err)
+	}
+	defer reg.Shutdown(ctx)
+
+	counter := reg.NewCounterVec(&CounterVecOpts{Name: "calls_total", Labels: []string{"method", "code"}})
+	expectPanic(t, "label count", func() {
+		counter.Inc("GET")
+	})
+	expectPanic(t, "different type", func() {
+		reg.NewHistogramVec(&HistogramVecOpts{Name: "calls_total", Labels: []string{"method", "code"}})
+	})
+	// 同名同类型返回已注册的指标
+	if again := reg.NewCounterVec(&CounterVecOpts{Name: "calls_total", Labels: []string{"method", "code"}}); again != counter {
+		t.Error("registering the same counter again should return the registered one")
+	}
+}
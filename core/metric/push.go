@@ -0,0 +1,377 @@
+package metric
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+// Push protocols.
+const (
+	// PushGateway pushes the text format to a Pushgateway, the metrics of the same grouping key are replaced
+	PushGateway = "pushgateway"
+	// RemoteWrite pushes the samples by the Prometheus remote-write protocol
+	RemoteWrite = "remote-write"
+)
+
+// PushOption is pusher option.
+type PushOption func(o *pushOptions)
+
+type pushOptions struct {
+	protocol string
+	job      string
+	grouping map[string]string
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+	attempt  time.Duration
+	timeout  time.Duration
+	client   *http.Client
+	headers  map[string]string
+}
+
+// WithPushProtocol sets the protocol, PushGateway by default.
+func WithPushProtocol(protocol string) PushOption {
+	return func(o *pushOptions) {
+		o.protocol = protocol
+	}
+}
+
+// WithPushJob sets the job label, "chaos" by default.
+func WithPushJob(job string) PushOption {
+	return func(o *pushOptions) {
+		o.job = job
+	}
+}
+
+// WithGrouping adds the grouping labels, they are the grouping key of the Pushgateway and the labels
+// of every remote-write series, instance is the hostname by default so that the instances of a job
+// don't replace the metrics of each other.
+func WithGrouping(labels map[string]string) PushOption {
+	return func(o *pushOptions) {
+		for k, v := range labels {
+			o.grouping[k] = v
+		}
+	}
+}
+
+// WithPushInterval pushes periodically, 0 only pushes on Stop.
+func WithPushInterval(interval time.Duration) PushOption {
+	return func(o *pushOptions) {
+		o.interval = interval
+	}
+}
+
+// WithPushRetry retries a failed push with exponential backoff, 3 times from 500ms by default.
+func WithPushRetry(retries int, backoff time.Duration) PushOption {
+	return func(o *pushOptions) {
+		o.retries = retries
+		o.backoff = backoff
+	}
+}
+
+// WithPushTimeout bounds every attempt and the whole push including the retries, 3s and 10s by default,
+// Stop in App.Stop can't use up the stop timeout of the other servers.
+func WithPushTimeout(attempt, total time.Duration) PushOption {
+	return func(o *pushOptions) {
+		o.attempt = attempt
+		o.timeout = total
+	}
+}
+
+// WithPushClient sets the http client, e.g. with tls.
+func WithPushClient(client *http.Client) PushOption {
+	return func(o *pushOptions) {
+		o.client = client
+	}
+}
+
+// WithPushHeaders sets the headers of the push requests, e.g. Authorization.
+func WithPushHeaders(headers map[string]string) PushOption {
+	return func(o *pushOptions) {
+		o.headers = headers
+	}
+}
+
+// Pusher pushes the metrics of a prometheus Registry for the short-lived jobs which exit before being scraped.
+// It implements server.Server, run it by app.WithServers to push periodically and on App.Stop.
+type Pusher struct {
+	url      string
+	gatherer prom.Gatherer
+	opts     pushOptions
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewPusher creates a pusher of reg, which must be created by NewRegistry or DefaultRegistry.
+func NewPusher(reg Registry, url string, opts ...PushOption) (*Pusher, error) {
+	pr, ok := reg.(*promRegistry)
+	if !ok {
+		return nil, errors.Errorf("push requires a prometheus registry, got %T", reg)
+	}
+	o := pushOptions{
+		protocol: PushGateway,
+		job:      "chaos",
+		grouping: make(map[string]string),
+		retries:  3,
+		backoff:  500 * time.Millisecond,
+		attempt:  3 * time.Second,
+		timeout:  10 * time.Second,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	if host, err := os.Hostname(); err == nil {
+		o.grouping["instance"] = host
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.protocol != PushGateway && o.protocol != RemoteWrite {
+		return nil, errors.Errorf("unsupported push protocol: %s", o.protocol)
+	}
+	return &Pusher{
+		url:      url,
+		gatherer: pr.gatherer,
+		opts:     o,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start pushes periodically until Stop.
+func (p *Pusher) Start(ctx context.Context) error {
+	if p.opts.interval <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return nil
+		case <-ticker.C:
+			if err := p.Push(ctx); err != nil {
+				log.Warnf("[metric] push metrics to %s error: %s", p.url, err)
+			}
+		}
+	}
+}
+
+// Stop stops the periodic push and pushes the last metrics.
+func (p *Pusher) Stop(ctx context.Context) error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	if err := p.Push(ctx); err != nil {
+		log.Errorf("[metric] push metrics to %s error: %s", p.url, err)
+		return err
+	}
+	return nil
+}
+
+// Push pushes the metrics now, it retries until the push timeout or ctx is done.
+func (p *Pusher) Push(ctx context.Context) error {
+	if p.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.timeout)
+		defer cancel()
+	}
+	var err error
+	backoff := p.opts.backoff
+	for i := 0; i <= p.opts.retries; i++ {
+		if i > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				// 返回最后一次推送的错误, 而不是 context deadline exceeded
+				return err
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+		if err = p.attemptPush(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (p *Pusher) attemptPush(ctx context.Context) error {
+	if p.opts.attempt > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.attempt)
+		defer cancel()
+	}
+	return p.push(ctx)
+}
+
+func (p *Pusher) push(ctx context.Context) error {
+	if p.opts.protocol == RemoteWrite {
+		return p.remoteWrite(ctx)
+	}
+	pusher := push.New(p.url, p.opts.job).Gatherer(p.gatherer).Client(&headerClient{client: p.opts.client, headers: p.opts.headers})
+	for k, v := range p.opts.grouping {
+		pusher = pusher.Grouping(k, v)
+	}
+	return pusher.PushContext(ctx)
+}
+
+// headerClient adds the headers to the Pushgateway requests.
+type headerClient struct {
+	client  *http.Client
+	headers map[string]string
+}
+
+func (c *headerClient) Do(req *http.Request) (*http.Response, error) {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return c.client.Do(req)
+}
+
+func (p *Pusher) remoteWrite(ctx context.Context) error {
+	mfs, err := p.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	extra := map[string]string{"job": p.opts.job}
+	for k, v := range p.opts.grouping {
+		extra[k] = v
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(mfs, extra, time.Now().UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range p.opts.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("remote write returns %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// encodeWriteRequest encodes the prometheus.WriteRequest protobuf, the histograms and summaries
+// are split into the series like the text format.
+func encodeWriteRequest(mfs []*dto.MetricFamily, extra map[string]string, ts int64) []byte {
+	var buf []byte
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, s := range samples(mf, m) {
+				for k, v := range extra {
+					if _, ok := s.labels[k]; !ok {
+						s.labels[k] = v
+					}
+				}
+				buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+				buf = protowire.AppendBytes(buf, encodeTimeSeries(s, ts))
+			}
+		}
+	}
+	return buf
+}
+
+func samples(mf *dto.MetricFamily, m *dto.Metric) []sample {
+	name := mf.GetName()
+	newSample := func(suffix string, value float64, kv ...string) sample {
+		labels := map[string]string{"__name__": name + suffix}
+		for _, lp := range m.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		for i := 0; i+1 < len(kv); i += 2 {
+			labels[kv[i]] = kv[i+1]
+		}
+		return sample{labels: labels, value: value}
+	}
+
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		return []sample{newSample("", m.GetCounter().GetValue())}
+	case dto.MetricType_GAUGE:
+		return []sample{newSample("", m.GetGauge().GetValue())}
+	case dto.MetricType_UNTYPED:
+		return []sample{newSample("", m.GetUntyped().GetValue())}
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		out := make([]sample, 0, len(s.GetQuantile())+2)
+		for _, q := range s.GetQuantile() {
+			out = append(out, newSample("", q.GetValue(), "quantile", formatFloat(q.GetQuantile())))
+		}
+		return append(out, newSample("_sum", s.GetSampleSum()), newSample("_count", float64(s.GetSampleCount())))
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		h := m.GetHistogram()
+		out := make([]sample, 0, len(h.GetBucket())+3)
+		for _, b := range h.GetBucket() {
+			out = append(out, newSample("_bucket", float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound())))
+		}
+		out = append(out, newSample("_bucket", float64(h.GetSampleCount()), "le", "+Inf"))
+		return append(out, newSample("_sum", h.GetSampleSum()), newSample("_count", float64(h.GetSampleCount())))
+	}
+	return nil
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func encodeTimeSeries(s sample, ts int64) []byte {
+	// remote-write 要求 label 按名字排序
+	names := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, s.labels[name])
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, label)
+	}
+	var smp []byte
+	smp = protowire.AppendTag(smp, 1, protowire.Fixed64Type)
+	smp = protowire.AppendFixed64(smp, math.Float64bits(s.value))
+	smp = protowire.AppendTag(smp, 2, protowire.VarintType)
+	smp = protowire.AppendVarint(smp, uint64(ts))
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	return protowire.AppendBytes(buf, smp)
+}
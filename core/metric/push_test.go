@@ -0,0 +1,230 @@
+package metric
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// pushReceiver is an in-process Pushgateway and remote-write receiver keeping the last request.
+type pushReceiver struct {
+	mu     sync.Mutex
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func (rc *pushReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc.mu.Lock()
+	rc.method, rc.path, rc.header, rc.body = r.Method, r.URL.Path, r.Header, body
+	rc.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func newPushRegistry(t *testing.T) Registry {
+	t.Helper()
+	reg := NewRegistry()
+	reg.NewCounterVec(&CounterVecOpts{Namespace: "push", Name: "jobs_total", Help: "jobs.", Labels: []string{"kind"}}).Add(3, "batch")
+	return reg
+}
+
+func TestPushGateway(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	rc := &pushReceiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	p, err := NewPusher(newPushRegistry(t), srv.URL, WithPushJob("etl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.method != http.MethodPut || rc.path != "/metrics/job/etl/instance/"+host {
+		t.Fatalf("request = %s %s, want PUT to the group of the instance", rc.method, rc.path)
+	}
+
+	// 默认是 varint 长度分隔的 protobuf
+	var value float64
+	for buf := rc.body; len(buf) > 0; {
+		msg, n := protowire.ConsumeBytes(buf)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		var mf dto.MetricFamily
+		if err := proto.Unmarshal(msg, &mf); err != nil {
+			t.Fatal(err)
+		}
+		if mf.GetName() == "push_jobs_total" {
+			value = mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if value != 3 {
+		t.Errorf("push_jobs_total = %v, want 3", value)
+	}
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	rc := &pushReceiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	p, err := NewPusher(newPushRegistry(t), srv.URL, WithPushProtocol(RemoteWrite),
+		WithPushJob("etl"), WithGrouping(map[string]string{"instance": "worker-1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.header.Get("Content-Encoding") != "snappy" || rc.header.Get("Content-Type") != "application/x-protobuf" {
+		t.Fatalf("headers = %v", rc.header)
+	}
+	body, err := snappy.Decode(nil, rc.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, ts := range decodeWriteRequest(t, body) {
+		if ts.labels["__name__"] != "push_jobs_total" {
+			continue
+		}
+		found = true
+		want := map[string]string{"__name__": "push_jobs_total", "kind": "batch", "job": "etl", "instance": "worker-1"}
+		if len(ts.labels) != len(want) {
+			t.Errorf("labels = %v, want %v", ts.labels, want)
+		}
+		for k, v := range want {
+			if ts.labels[k] != v {
+				t.Errorf("label %s = %q, want %q", k, ts.labels[k], v)
+			}
+		}
+		if ts.value != 3 {
+			t.Errorf("value = %v, want 3", ts.value)
+		}
+	}
+	if !found {
+		t.Error("no push_jobs_total series")
+	}
+}
+
+func TestPushStopIsBounded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	p, err := NewPusher(newPushRegistry(t), srv.URL, WithPushRetry(3, 50*time.Millisecond),
+		WithPushTimeout(100*time.Millisecond, 300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := p.Stop(context.Background()); err == nil {
+		t.Fatal("push to the hanging server should fail")
+	}
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("Stop took %s, want it bounded by the push timeout", elapsed)
+	}
+}
+
+// decodeWriteRequest decodes the prometheus.WriteRequest with one sample per series.
+func decodeWriteRequest(t *testing.T, buf []byte) []sample {
+	t.Helper()
+	var out []sample
+	each(t, buf, func(num protowire.Number, v []byte, _ uint64) {
+		s := sample{labels: map[string]string{}}
+		each(t, v, func(num protowire.Number, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				var name, value string
+				each(t, v, func(num protowire.Number, v []byte, _ uint64) {
+					if num == 1 {
+						name = string(v)
+					} else {
+						value = string(v)
+					}
+				})
+				s.labels[name] = value
+			case 2:
+				each(t, v, func(num protowire.Number, _ []byte, n uint64) {
+					if num == 1 {
+						s.value = math.Float64frombits(n)
+					}
+				})
+			}
+		})
+		out = append(out, s)
+	})
+	return out
+}
+
+// each calls fn with every field of the message buf, the bytes for the length-delimited fields,
+// otherwise the number.
+func each(t *testing.T, buf []byte, fn func(num protowire.Number, v []byte, n uint64)) {
+	t.Helper()
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		buf = buf[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(buf)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			buf = buf[n:]
+			fn(num, v, 0)
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(buf)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			buf = buf[n:]
+			fn(num, nil, v)
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(buf)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			buf = buf[n:]
+			fn(num, nil, v)
+		default:
+			t.Fatalf("field %d has type %d", num, typ)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is the metrics backend, it creates and registers the metrics, creating a metric
+// of the same name again returns the registered one. NewRegistry is backed by prometheus,
+// NewOTelRegistry is backed by the otel metric SDK.
+type Registry interface {
+	NewCounterVec(cfg *CounterVecOpts) CounterVec
+	NewGaugeVec(cfg *GaugeVecOpts) GaugeVec
+	NewHistogramVec(cfg *HistogramVecOpts) HistogramVec
+	NewSummaryVec(cfg *SummaryVecOpts) SummaryVec
+	// Handler serves the metrics of the registry
+	Handler() http.Handler
+}
+
+// RegistryOption is registry option.
+type RegistryOption func(r *promRegistry)
+
+// WithConstLabels adds the labels to every metric of the registry, e.g. service, instance, version.
+func WithConstLabels(labels map[string]string) RegistryOption {
+	return func(r *promRegistry) {
+		for k, v := range labels {
+			r.constLabels[k] = v
+		}
+	}
+}
+
+// WithPrometheus uses reg instead of a new prometheus registry.
+func WithPrometheus(reg *prom.Registry) RegistryOption {
+	return func(r *promRegistry) {
+		r.registerer = reg
+		r.gatherer = reg
+	}
+}
+
+type promRegistry struct {
+	registerer  prom.Registerer
+	gatherer    prom.Gatherer
+	constLabels prom.Labels
+
+	mu         sync.Mutex
+	collectors map[string]registered
+}
+
+// registered is a collector with the spec it is created by.
+type registered struct {
+	collector prom.Collector
+	spec      string
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRegistry Registry = newPromRegistry(prom.DefaultRegisterer, prom.DefaultGatherer)
+)
+
+// DefaultRegistry returns the registry of the prometheus default registerer, it is used by
+// NewCounterVec, NewGaugeVec and NewHistogramVec.
+func DefaultRegistry() Registry {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultRegistry
+}
+
+// SetDefaultRegistry replaces the default registry, e.g. by an OTelRegistry, call it before creating
+// the servers and clients, the metrics created before keep recording to the former registry.
+func SetDefaultRegistry(r Registry) {
+	defaultMu.Lock()
+	defaultRegistry = r
+	defaultMu.Unlock()
+}
+
+// NewRegistry creates a registry of a new prometheus registry with the go and process collectors.
+func NewRegistry(opts ...RegistryOption) Registry {
+	r := newPromRegistry(nil, nil)
+	for _, o := range opts {
+		o(r)
+	}
+	if r.registerer == nil {
+		reg := prom.NewRegistry()
+		reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+		r.registerer, r.gatherer = reg, reg
+	}
+	return r
+}
+
+func newPromRegistry(registerer prom.Registerer, gatherer prom.Gatherer) *promRegistry {
+	return &promRegistry{
+		registerer:  registerer,
+		gatherer:    gatherer,
+		constLabels: prom.Labels{},
+		collectors:  make(map[string]registered),
+	}
+}
+
+func (r *promRegistry) NewCounterVec(cfg *CounterVecOpts) CounterVec {
+	if cfg == nil {
+		return nil
+	}
+	spec := fmt.Sprintf("counter labels=%v", cfg.Labels)
+	vec := r.register(prom.BuildFQName(cfg.Namespace, cfg.Subsystem, cfg.Name), spec, func() prom.Collector {
+		return prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        cfg.Name,
+			Help:        cfg.Help,
+			ConstLabels: r.constLabels,
+		}, cfg.Labels)
+	})
+	return &promCounterVec{counter: vec.(*prom.CounterVec)}
+}
+
+func (r *promRegistry) NewGaugeVec(cfg *GaugeVecOpts) GaugeVec {
+	if cfg == nil {
+		return nil
+	}
+	spec := fmt.Sprintf("gauge labels=%v", cfg.Labels)
+	vec := r.register(prom.BuildFQName(cfg.Namespace, cfg.Subsystem, cfg.Name), spec, func() prom.Collector {
+		return prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        cfg.Name,
+			Help:        cfg.Help,
+			ConstLabels: r.constLabels,
+		}, cfg.Labels)
+	})
+	return &promGaugeVec{gauge: vec.(*prom.GaugeVec)}
+}
+
+func (r *promRegistry) NewHistogramVec(cfg *HistogramVecOpts) HistogramVec {
+	if cfg == nil {
+		return nil
+	}
+	buckets := cfg.Buckets
+	if len(buckets) == 0 {
+		buckets = prom.DefBuckets
+	}
+	spec := fmt.Sprintf("histogram labels=%v buckets=%v native=%v/%d",
+		cfg.Labels, buckets, cfg.NativeBucketFactor, nativeMaxBuckets(cfg))
+	vec := r.register(prom.BuildFQName(cfg.Namespace, cfg.Subsystem, cfg.Name), spec, func() prom.Collector {
+		return prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        cfg.Name,
+			Help:        cfg.Help,
+			ConstLabels: r.constLabels,
+			Buckets:     cfg.Buckets,
+
+			NativeHistogramBucketFactor:    cfg.NativeBucketFactor,
+			NativeHistogramMaxBucketNumber: nativeMaxBuckets(cfg),
+		}, cfg.Labels)
+	})
+	return &promHistogramVec{histogram: vec.(*prom.HistogramVec)}
+}
+
+func nativeMaxBuckets(cfg *HistogramVecOpts) uint32 {
+	if cfg.NativeBucketFactor <= 1 || cfg.NativeMaxBuckets > 0 {
+		return cfg.NativeMaxBuckets
+	}
+	return 160
+}
+
+func (r *promRegistry) NewSummaryVec(cfg *SummaryVecOpts) SummaryVec {
+	if cfg == nil {
+		return nil
+	}
+	spec := fmt.Sprintf("summary labels=%v objectives=%v maxage=%s", cfg.Labels, cfg.Objectives, cfg.MaxAge)
+	vec := r.register(prom.BuildFQName(cfg.Namespace, cfg.Subsystem, cfg.Name), spec, func() prom.Collector {
+		return prom.NewSummaryVec(prom.SummaryOpts{
+			Namespace:   cfg.Namespace,
+			Subsystem:   cfg.Subsystem,
+			Name:        cfg.Name,
+			Help:        cfg.Help,
+			ConstLabels: r.constLabels,
+			Objectives:  cfg.Objectives,
+			MaxAge:      cfg.MaxAge,
+		}, cfg.Labels)
+	})
+	return &promSummaryVec{summary: vec.(*prom.SummaryVec)}
+}
+
+func (r *promRegistry) Handler() http.Handler {
+	// exemplars 只在 OpenMetrics 格式中输出
+	return promhttp.HandlerFor(r.gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// register returns the registered collector of the name, or registers the new one,
+// it panics if the name is registered as another type or with other labels, buckets or objectives,
+// instead of failing later in WithLabelValues.
+func (r *promRegistry) register(name, spec string, create func() prom.Collector) prom.Collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.collectors[name]; ok {
+		if existing.spec != spec {
+			panic(fmt.Sprintf("metric: %s is registered as %s, not %s", name, existing.spec, spec))
+		}
+		return existing.collector
+	}
+	c := create()
+	if err := r.registerer.Register(c); err != nil {
+		are, ok := err.(prom.AlreadyRegisteredError)
+		if !ok {
+			// 同名但 help、标签或常量标签不同
+			panic(fmt.Sprintf("metric: register %s as %s: %v", name, spec, err))
+		}
+		// 其他的 Registry 注册到了同一个 prometheus registry, 描述符 (标签和常量标签) 一致,
+		// 但无法比较 buckets 和 objectives
+		c = sameType(name, are.ExistingCollector, c)
+	}
+	r.collectors[name] = registered{collector: c, spec: spec}
+	return c
+}
+
+func sameType(name string, existing prom.Collector, c prom.Collector) prom.Collector {
+	if fmt.Sprintf("%T", existing) != fmt.Sprintf("%T", c) {
+		panic(fmt.Sprintf("metric: %s is registered as %T", name, existing))
+	}
+	return existing
+}
+
+type nopRegistry struct{}
+
+// NewNopRegistry returns a registry which drops all observations, it is used in tests.
+func NewNopRegistry() Registry {
+	return nopRegistry{}
+}
+
+func (nopRegistry) NewCounterVec(*CounterVecOpts) CounterVec       { return nopVec{} }
+func (nopRegistry) NewGaugeVec(*GaugeVecOpts) GaugeVec             { return nopVec{} }
+func (nopRegistry) NewHistogramVec(*HistogramVecOpts) HistogramVec { return nopVec{} }
+func (nopRegistry) NewSummaryVec(*SummaryVecOpts) SummaryVec       { return nopSummary{} }
+func (nopRegistry) Handler() http.Handler                          { return http.NotFoundHandler() }
+
+type nopVec struct{}
+
+func (nopVec) Inc(...string)                                      {}
+func (nopVec) Add(float64, ...string)                             {}
+func (nopVec) Set(float64, ...string)                             {}
+func (nopVec) Observe(int64, ...string)                           {}
+func (nopVec) ObserveFloat(float64, ...string)                    {}
+func (nopVec) ObserveContext(context.Context, float64, ...string) {}
+
+type nopSummary struct{}
+
+func (nopSummary) Observe(float64, ...string) {}
@@ -0,0 +1,161 @@
+package metric
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gather(t *testing.T, reg *prom.Registry, name string) *dto.MetricFamily {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == name {
+			return mf
+		}
+	}
+	t.Fatalf("no metric %s", name)
+	return nil
+}
+
+func TestRegistryIdempotent(t *testing.T) {
+	reg := prom.NewRegistry()
+	r := NewRegistry(WithPrometheus(reg))
+	opts := &CounterVecOpts{Namespace: "test", Name: "requests_total", Help: "requests.", Labels: []string{"code"}}
+	r.NewCounterVec(opts).Inc("200")
+	r.NewCounterVec(opts).Add(2, "200")
+
+	// 共享同一个 prometheus registry 的 Registry 也返回已注册的
+	NewRegistry(WithPrometheus(reg)).NewCounterVec(opts).Inc("200")
+
+	mf := gather(t, reg, "test_requests_total")
+	if len(mf.GetMetric()) != 1 || mf.GetMetric()[0].GetCounter().GetValue() != 4 {
+		t.Errorf("metric = %v, want one series of 4", mf.GetMetric())
+	}
+}
+
+func TestRegistryMismatchPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(r Registry)
+	}{
+		{"counter labels", func(r Registry) {
+			r.NewCounterVec(&CounterVecOpts{Name: "m", Labels: []string{"a"}})
+			r.NewCounterVec(&CounterVecOpts{Name: "m", Labels: []string{"a", "b"}})
+		}},
+		{"type", func(r Registry) {
+			r.NewCounterVec(&CounterVecOpts{Name: "m", Labels: []string{"a"}})
+			r.NewGaugeVec(&GaugeVecOpts{Name: "m", Labels: []string{"a"}})
+		}},
+		{"buckets", func(r Registry) {
+			r.NewHistogramVec(&HistogramVecOpts{Name: "m", Buckets: []float64{1, 2}})
+			r.NewHistogramVec(&HistogramVecOpts{Name: "m", Buckets: []float64{1, 5}})
+		}},
+		{"default buckets", func(r Registry) {
+			r.NewHistogramVec(&HistogramVecOpts{Name: "m"})
+			r.NewHistogramVec(&HistogramVecOpts{Name: "m", Buckets: []float64{1}})
+		}},
+		{"native histogram", func(r Registry) {
+			r.NewHistogramVec(&HistogramVecOpts{Name: "m"})
+			r.NewHistogramVec(&HistogramVecOpts{Name: "m", NativeBucketFactor: 1.1})
+		}},
+		{"objectives", func(r Registry) {
+			r.NewSummaryVec(&SummaryVecOpts{Name: "m", Objectives: map[float64]float64{0.5: 0.05}})
+			r.NewSummaryVec(&SummaryVecOpts{Name: "m", Objectives: map[float64]float64{0.99: 0.001}})
+		}},
+		{"labels of another registry", func(r Registry) {
+			reg := prom.NewRegistry()
+			NewRegistry(WithPrometheus(reg)).NewCounterVec(&CounterVecOpts{Name: "m", Labels: []string{"a"}})
+			NewRegistry(WithPrometheus(reg)).NewCounterVec(&CounterVecOpts{Name: "m", Labels: []string{"b"}})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("register should panic")
+				}
+			}()
+			tt.register(NewRegistry(WithPrometheus(prom.NewRegistry())))
+		})
+	}
+
+	// 默认 buckets 和显式的 DefBuckets 是同一个
+	r := NewRegistry(WithPrometheus(prom.NewRegistry()))
+	r.NewHistogramVec(&HistogramVecOpts{Name: "h"})
+	r.NewHistogramVec(&HistogramVecOpts{Name: "h", Buckets: prom.DefBuckets})
+}
+
+func TestRegistryConstLabels(t *testing.T) {
+	reg := prom.NewRegistry()
+	r := NewRegistry(WithPrometheus(reg), WithConstLabels(map[string]string{"service": "user"}),
+		WithConstLabels(map[string]string{"version": "v1"}))
+	r.NewGaugeVec(&GaugeVecOpts{Name: "inflight", Labels: []string{"method"}}).Set(3, "GET")
+
+	got := map[string]string{}
+	for _, l := range gather(t, reg, "inflight").GetMetric()[0].GetLabel() {
+		got[l.GetName()] = l.GetValue()
+	}
+	want := map[string]string{"service": "user", "version": "v1", "method": "GET"}
+	if len(got) != len(want) {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("label %s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `inflight{method="GET",service="user",version="v1"} 3`) {
+		t.Errorf("/metrics = %s", rec.Body.String())
+	}
+}
+
+func TestNopRegistry(t *testing.T) {
+	r := NewNopRegistry()
+	r.NewCounterVec(&CounterVecOpts{Name: "c"}).Inc()
+	r.NewGaugeVec(&GaugeVecOpts{Name: "g"}).Set(1)
+	r.NewHistogramVec(&HistogramVecOpts{Name: "h"}).Observe(1)
+	r.NewSummaryVec(&SummaryVecOpts{Name: "s"}).Observe(1)
+	// 不同的标签也不会 panic
+	r.NewCounterVec(&CounterVecOpts{Name: "c", Labels: []string{"a"}}).Inc("x", "y")
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestSetDefaultRegistry(t *testing.T) {
+	old := DefaultRegistry()
+	defer SetDefaultRegistry(old)
+
+	nop := NewNopRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetDefaultRegistry(nop)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = DefaultRegistry()
+		}()
+	}
+	wg.Wait()
+	if DefaultRegistry() != nop {
+		t.Error("DefaultRegistry should return the registry set")
+	}
+}
@@ -0,0 +1,42 @@
+package metric
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// A SummaryVecOpts is a summary vector options.
+	SummaryVecOpts struct {
+		Namespace string
+		Subsystem string
+		Name      string
+		Help      string
+		Labels    []string
+		// Objectives are the quantiles with their absolute errors, e.g. {0.5: 0.05, 0.99: 0.001}
+		Objectives map[float64]float64
+		// MaxAge is the duration of the observations for the quantiles, 10m by default
+		MaxAge time.Duration
+	}
+
+	// A SummaryVec interface represents a summary vector, the quantiles are calculated in process
+	// and can not be aggregated between instances, prefer HistogramVec if so.
+	SummaryVec interface {
+		// Observe adds observation v to labels.
+		Observe(v float64, labels ...string)
+	}
+
+	promSummaryVec struct {
+		summary *prom.SummaryVec
+	}
+)
+
+// NewSummaryVec returns a SummaryVec of the default registry.
+func NewSummaryVec(cfg *SummaryVecOpts) SummaryVec {
+	return DefaultRegistry().NewSummaryVec(cfg)
+}
+
+func (sv *promSummaryVec) Observe(v float64, labels ...string) {
+	sv.summary.WithLabelValues(labels...).Observe(v)
+}
@@ -0,0 +1,65 @@
+package metric
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSummaryObjectives(t *testing.T) {
+	reg := prom.NewRegistry()
+	r := NewRegistry(WithPrometheus(reg))
+	objectives := map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+	sv := r.NewSummaryVec(&SummaryVecOpts{Name: "size_bytes", Labels: []string{"kind"}, Objectives: objectives})
+	for i := 1; i <= 1000; i++ {
+		sv.Observe(float64(i), "upload")
+	}
+
+	s := gather(t, reg, "size_bytes").GetMetric()[0].GetSummary()
+	if s.GetSampleCount() != 1000 || s.GetSampleSum() != 500500 {
+		t.Errorf("count = %d, sum = %v", s.GetSampleCount(), s.GetSampleSum())
+	}
+	if len(s.GetQuantile()) != len(objectives) {
+		t.Fatalf("quantiles = %v, want %v", s.GetQuantile(), objectives)
+	}
+	for _, q := range s.GetQuantile() {
+		e, ok := objectives[q.GetQuantile()]
+		if !ok {
+			t.Errorf("unexpected quantile %v", q.GetQuantile())
+			continue
+		}
+		// 排名的误差不超过 objective 给出的绝对误差
+		if rank := q.GetValue() / 1000; math.Abs(rank-q.GetQuantile()) > e {
+			t.Errorf("quantile %v = %v, want within %v", q.GetQuantile(), q.GetValue(), e)
+		}
+	}
+
+	// 没有 objectives 时只有 count 和 sum
+	r.NewSummaryVec(&SummaryVecOpts{Name: "plain_bytes"}).Observe(1)
+	if q := gather(t, reg, "plain_bytes").GetMetric()[0].GetSummary().GetQuantile(); len(q) != 0 {
+		t.Errorf("quantiles = %v, want none", q)
+	}
+}
+
+func TestSummaryMaxAge(t *testing.T) {
+	reg := prom.NewRegistry()
+	sv := NewRegistry(WithPrometheus(reg)).NewSummaryVec(&SummaryVecOpts{
+		Name: "age_ms", Objectives: map[float64]float64{0.5: 0.05}, MaxAge: 100 * time.Millisecond,
+	})
+	sv.Observe(42)
+	if v := gather(t, reg, "age_ms").GetMetric()[0].GetSummary().GetQuantile()[0].GetValue(); v != 42 {
+		t.Fatalf("median = %v, want 42", v)
+	}
+
+	// 超过 MaxAge 的观测不再计入分位数, count 不受影响
+	time.Sleep(150 * time.Millisecond)
+	s := gather(t, reg, "age_ms").GetMetric()[0].GetSummary()
+	if v := s.GetQuantile()[0].GetValue(); !math.IsNaN(v) {
+		t.Errorf("median = %v, want NaN after max age", v)
+	}
+	if s.GetSampleCount() != 1 {
+		t.Errorf("count = %d, want 1", s.GetSampleCount())
+	}
+}
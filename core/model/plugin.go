@@ -0,0 +1,300 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/core/metric"
+	trace2 "github.com/chaos-ma/chaos/core/trace"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+const (
+	pluginName = "chaos:instrumentation"
+	startKey   = "chaos:start"
+	parentKey  = "chaos:parent"
+	// maxSQLLen 限制 span 和日志中 sql 的长度
+	maxSQLLen = 2048
+)
+
+// rowsAffectedKey 不在 semconv 中
+const rowsAffectedKey = attribute.Key("db.rows_affected")
+
+// PluginOption is the gorm plugin option.
+type PluginOption func(p *Plugin)
+
+// WithSlowThreshold logs the queries slower than d, 200ms by default, 0 disables it.
+func WithSlowThreshold(d time.Duration) PluginOption {
+	return func(p *Plugin) {
+		p.slowThreshold = d
+	}
+}
+
+// WithPluginRegistry sets the metric registry, metric.DefaultRegistry() by default.
+func WithPluginRegistry(reg metric.Registry) PluginOption {
+	return func(p *Plugin) {
+		p.registry = reg
+	}
+}
+
+// WithTracing enables the spans, true by default.
+func WithTracing(on bool) PluginOption {
+	return func(p *Plugin) {
+		p.tracing = on
+	}
+}
+
+// WithErrorCode wraps the db errors with code.ErrDatabase, true by default,
+// gorm.ErrRecordNotFound and the errors with code are not wrapped.
+func WithErrorCode(on bool) PluginOption {
+	return func(p *Plugin) {
+		p.errorCode = on
+	}
+}
+
+// Plugin is the gorm plugin which traces the queries, records the duration and logs the slow queries:
+//
+//	db.Use(model.NewPlugin(model.WithSlowThreshold(time.Second)))
+type Plugin struct {
+	slowThreshold time.Duration
+	registry      metric.Registry
+	tracing       bool
+	errorCode     bool
+
+	system string
+	// quotedIdent 表示双引号是标识符而不是字符串
+	quotedIdent bool
+	duration    metric.HistogramVec
+	errors      metric.CounterVec
+}
+
+var _ gorm.Plugin = (*Plugin)(nil)
+
+// NewPlugin creates the gorm plugin.
+func NewPlugin(opts ...PluginOption) *Plugin {
+	p := &Plugin{
+		slowThreshold: 200 * time.Millisecond,
+		tracing:       true,
+		errorCode:     true,
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	if p.registry == nil {
+		p.registry = metric.DefaultRegistry()
+	}
+	return p
+}
+
+func (p *Plugin) Name() string {
+	return pluginName
+}
+
+// Initialize registers the callbacks around every operation.
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	p.system = db.Dialector.Name()
+	switch p.system {
+	case "postgres", "sqlite", "sqlserver":
+		p.quotedIdent = true
+	}
+	labels := []string{"table", "operation"}
+	p.duration = p.registry.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: "db_client",
+		Subsystem: "queries",
+		Name:      "duration_ms",
+		Help:      "db client queries duration(ms).",
+		Labels:    labels,
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+	p.errors = p.registry.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: "db_client",
+		Subsystem: "queries",
+		Name:      "errors_total",
+		Help:      "db client queries error count.",
+		Labels:    labels,
+	})
+
+	cb := db.Callback()
+	processors := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	}
+	for _, proc := range processors {
+		if err := proc.before(pluginName+":before_"+proc.operation, p.before(proc.operation)); err != nil {
+			return err
+		}
+		if err := proc.after(pluginName+":after_"+proc.operation, p.after(proc.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+		if !p.tracing || db.Statement.Context == nil {
+			return
+		}
+		// 复用的 Statement 在 after 中恢复原来的 context
+		db.InstanceSet(parentKey, db.Statement.Context)
+		ctx, _ := otel.Tracer(trace2.TraceName).Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+	}
+}
+
+func (p *Plugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		table := db.Statement.Table
+		sql := sanitizeSQL(db.Statement.SQL.String(), p.quotedIdent)
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+
+		var elapsed time.Duration
+		if v, ok := db.InstanceGet(startKey); ok {
+			elapsed = time.Since(v.(time.Time))
+		}
+		p.duration.ObserveContext(ctx, float64(elapsed)/float64(time.Millisecond), table, operation)
+		if err != nil {
+			p.errors.Inc(table, operation)
+		}
+
+		span := trace.SpanFromContext(ctx)
+		if p.slowThreshold > 0 && elapsed >= p.slowThreshold {
+			fields := []log.Field{log.String("table", table), log.Int64("rows", db.Statement.RowsAffected), log.Duration("elapsed", elapsed)}
+			// 未记录的 span 日志中没有 trace_id, 这里补上
+			if sc := span.SpanContext(); !span.IsRecording() && sc.HasTraceID() {
+				fields = append(fields, log.String("trace_id", sc.TraceID().String()))
+			}
+			log.WarnC(ctx, "[gorm] slow query: "+sql, fields...)
+		}
+
+		if p.tracing {
+			if span.IsRecording() {
+				span.SetAttributes(
+					semconv.DBSystemKey.String(p.system),
+					semconv.DBOperation(operation),
+					semconv.DBSQLTable(table),
+					semconv.DBStatement(sql),
+					rowsAffectedKey.Int64(db.Statement.RowsAffected),
+				)
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(otelcodes.Error, err.Error())
+				}
+				span.End()
+			}
+			if parent, ok := db.InstanceGet(parentKey); ok {
+				db.Statement.Context = parent.(context.Context)
+			}
+		}
+
+		// ParseCoder 对没有错误码的 error 返回 code 为 1 的 unknown coder, 已有错误码的保持不变
+		if p.errorCode && err != nil && errors.ParseCoder(err).Code() == 1 {
+			db.Error = errors.WrapC(err, code.ErrDatabase, "%s %s failed", operation, table)
+		}
+	}
+}
+
+// sanitizeSQL replaces the string and numeric literals with ?, the placeholders like ? and $1, and the
+// identifiers quoted by backticks are kept, the double quoted strings are identifiers if quotedIdent,
+// otherwise they are literals like in mysql.
+func sanitizeSQL(sql string, quotedIdent bool) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	for i := 0; i < len(sql) && b.Len() < maxSQLLen; {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' && !quotedIdent:
+			i = skipQuoted(sql, i)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			j := skipQuoted(sql, i)
+			b.WriteString(sql[i:j])
+			i = j
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			// postgres 的占位符
+			j := i + 1
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		case isDigit(c):
+			// 包括 1.5, 1e10, 0x1f
+			j := i + 1
+			for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '.') {
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		case isIdentChar(c):
+			// 标识符中的数字保持不变, 例如 t1
+			j := i + 1
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	sql = b.String()
+	if len(sql) > maxSQLLen {
+		sql = sql[:maxSQLLen]
+	}
+	return sql
+}
+
+// skipQuoted returns the index after the quoted string starting at i, the doubled quote and
+// the backslash escape are inside the string, an unterminated string runs to the end.
+func skipQuoted(sql string, i int) int {
+	q := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if q != '`' {
+				j++
+			}
+		case q:
+			if j+1 < len(sql) && sql[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
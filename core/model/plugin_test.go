@@ -0,0 +1,190 @@
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		name        string
+		sql         string
+		quotedIdent bool
+		want        string
+	}{
+		{"numbers", "SELECT * FROM t1 WHERE id = 42 AND score > 1.5 LIMIT 10", false, "SELECT * FROM t1 WHERE id = ? AND score > ? LIMIT ?"},
+		{"single quoted", "SELECT * FROM users WHERE name = 'it''s' AND mail = 'a\\'b'", false, "SELECT * FROM users WHERE name = ? AND mail = ?"},
+		{"mysql double quoted", `SELECT * FROM users WHERE email = "bob@example.com"`, false, "SELECT * FROM users WHERE email = ?"},
+		{"postgres placeholders", `SELECT * FROM "users" WHERE "id" = $1 AND "age" > $12`, true, `SELECT * FROM "users" WHERE "id" = $1 AND "age" > $12`},
+		{"backticks", "SELECT `col1` FROM `t2` WHERE x = 0x1f", false, "SELECT `col1` FROM `t2` WHERE x = ?"},
+		{"unterminated", "SELECT 'secret", false, "SELECT ?"},
+		{"placeholders", "INSERT INTO t (a, b) VALUES (?, ?)", false, "INSERT INTO t (a, b) VALUES (?, ?)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeSQL(tt.sql, tt.quotedIdent); got != tt.want {
+				t.Errorf("sanitizeSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+	if got := sanitizeSQL(strings.Repeat("a", 3*maxSQLLen), false); len(got) != maxSQLLen {
+		t.Errorf("len = %d, want %d", len(got), maxSQLLen)
+	}
+}
+
+type user struct {
+	ID    uint
+	Email string
+}
+
+func openTestDB(t *testing.T, opts ...PluginOption) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&user{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(NewPlugin(opts...)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestPluginSpanAndMetrics(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()), sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	reg := prom.NewRegistry()
+	db := openTestDB(t, WithPluginRegistry(metric.NewRegistry(metric.WithPrometheus(reg))), WithSlowThreshold(0))
+	ctx := context.Background()
+	if err := db.WithContext(ctx).Create(&user{Email: "bob@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var u user
+	if err := db.WithContext(ctx).Where("email = ?", "bob@example.com").First(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	query := spans[1]
+	if query.Name() != "gorm.query" {
+		t.Errorf("span name = %q, want gorm.query", query.Name())
+	}
+	attrs := map[string]string{}
+	for _, kv := range query.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs[string(semconv.DBSystemKey)] != "sqlite" || attrs[string(semconv.DBSQLTableKey)] != "users" {
+		t.Errorf("attributes = %v", attrs)
+	}
+	if stmt := attrs[string(semconv.DBStatementKey)]; stmt == "" || strings.Contains(stmt, "bob@example.com") {
+		t.Errorf("db.statement = %q, want the sanitized sql", stmt)
+	}
+
+	if n := testutil.CollectAndCount(reg, "db_client_queries_duration_ms"); n != 2 {
+		t.Fatalf("duration series = %d, want 2", n)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, labels := range [][]string{{"users", "create"}, {"users", "query"}} {
+		found := false
+		for _, mf := range families {
+			if mf.GetName() != "db_client_queries_duration_ms" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				got := map[string]string{}
+				for _, l := range m.GetLabel() {
+					got[l.GetName()] = l.GetValue()
+				}
+				if got["table"] == labels[0] && got["operation"] == labels[1] {
+					found = m.GetHistogram().GetSampleCount() == 1
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no duration sample with table=%s operation=%s", labels[0], labels[1])
+		}
+	}
+}
+
+func TestPluginSlowQueryLog(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "log.json")
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{out}
+	log.Init(opts)
+	defer log.Init(log.NewOptions())
+
+	db := openTestDB(t, WithTracing(false), WithPluginRegistry(metric.NewNopRegistry()), WithSlowThreshold(time.Nanosecond))
+	var u user
+	_ = db.Where("email = ?", "alice@example.com").Find(&u).Error
+	log.Flush()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[gorm] slow query: SELECT") {
+		t.Fatalf("no slow query log in:\n%s", data)
+	}
+	if strings.Contains(string(data), "alice@example.com") {
+		t.Errorf("the slow query log leaks the literal:\n%s", data)
+	}
+}
+
+func TestPluginErrorCode(t *testing.T) {
+	db := openTestDB(t, WithTracing(false), WithPluginRegistry(metric.NewNopRegistry()), WithSlowThreshold(0))
+
+	var u user
+	err := db.First(&u, 404).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if c := errors.ParseCoder(err).Code(); c == code.ErrDatabase {
+		t.Errorf("gorm.ErrRecordNotFound is wrapped with code %d", c)
+	}
+
+	err = db.Table("missing").Where("id = ?", 1).Find(&u).Error
+	if err == nil {
+		t.Fatal("query on the missing table should fail")
+	}
+	if c := errors.ParseCoder(err).Code(); c != code.ErrDatabase {
+		t.Errorf("code = %d, want code.ErrDatabase", c)
+	}
+}
@@ -5,16 +5,22 @@ package trace
  */
 
 import (
+	"context"
+	"os"
 	"sync"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/exporters/zipkin"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 
+	"github.com/chaos-ma/chaos/errors"
 	"github.com/chaos-ma/chaos/log"
 )
 
@@ -22,62 +28,166 @@ import (
 初始化不同的export的设置
 */
 
-const (
-	kindJaeger = "jaeger"
-	kindZipkin = "zipkin"
-)
-
 var (
-	//set ,struct 空结构体不占内存， zerobase
-	agents = make(map[string]struct{})
+	//同一个 endpoint 只初始化一次, 保存它的 shutdown
+	agents = make(map[string]func(context.Context) error)
 	lock   sync.Mutex
 )
 
-func InitAgent(o Options) {
+// InitAgent sets the global tracer provider, the returned shutdown flushes the spans,
+// pass it to app.WithAfterStop to flush on exit.
+func InitAgent(o Options) (shutdown func(context.Context) error, err error) {
 	lock.Lock()
 	defer lock.Unlock()
 
-	_, ok := agents[o.Endpoint]
-	if ok {
-		return
+	if shutdown, ok := agents[o.Endpoint]; ok {
+		return shutdown, nil
 	}
-	err := startAgent(o)
+	shutdown, err = startAgent(o)
 	if err != nil {
-		return
+		log.Errorf("[trace] start agent error: %s", err)
+		return nil, err
 	}
-	agents[o.Endpoint] = struct{}{}
+	agents[o.Endpoint] = shutdown
+	return shutdown, nil
 }
 
-func startAgent(o Options) error {
-	var sexp trace.SpanExporter
-	var err error
-
-	opts := []trace.TracerProviderOption{
-		trace.WithSampler(trace.ParentBased(trace.TraceIDRatioBased(o.Sampler))),
-		trace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String(o.Name))),
+func startAgent(o Options) (func(context.Context) error, error) {
+	sampler, err := newSampler(o)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(o.Endpoint) > 0 {
-		switch o.Batcher {
-		case kindJaeger:
-			sexp, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(o.Endpoint)))
-			if err != nil {
-				return err
-			}
-		case kindZipkin:
-			sexp, err = zipkin.New(o.Endpoint)
-			if err != nil {
-				return err
-			}
-		}
-		opts = append(opts, trace.WithBatcher(sexp))
+	propagator, err := NewPropagator(o.Propagators)
+	if err != nil {
+		return nil, err
+	}
+	res, err := newResource(o)
+	if err != nil {
+		return nil, err
+	}
+	sexp, closer, err := newExporter(o)
+	if err != nil {
+		return nil, err
+	}
+	opts := []trace.TracerProviderOption{trace.WithResource(res)}
+	switch {
+	case sexp != nil && o.TailSampling != nil:
+		// 未被采样的 span 也记录下来, 由 tail processor 决定是否导出
+		opts = append(opts,
+			trace.WithSampler(NewTailSampler(sampler)),
+			trace.WithSpanProcessor(NewTailProcessor(trace.NewBatchSpanProcessor(sexp), *o.TailSampling)),
+		)
+	case sexp != nil:
+		opts = append(opts, trace.WithSampler(sampler), trace.WithBatcher(sexp))
+	default:
+		opts = append(opts, trace.WithSampler(sampler))
 	}
 
 	tp := trace.NewTracerProvider(opts...)
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	// otelgin, otelgrpc 和 clientinterceptors 都使用全局的 propagator
+	otel.SetTextMapPropagator(propagator)
 	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
 		log.Errorf("[otel] error: %v", err)
 	}))
-	return nil
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// newExporter creates the exporter of the batcher, the closer closes the file of the file batcher.
+func newExporter(o Options) (exp trace.SpanExporter, closer func() error, err error) {
+	switch o.Batcher {
+	case kindStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exp, nil, err
+	case kindFile:
+		if o.Endpoint == "" {
+			return nil, nil, errors.New("file batcher requires the file path as endpoint")
+		}
+		f, err := os.OpenFile(o.Endpoint, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exp, f.Close, nil
+	}
+
+	// 没有 endpoint 时只创建 span, 不导出
+	if len(o.Endpoint) == 0 {
+		return nil, nil, nil
+	}
+	switch o.Batcher {
+	case kindJaeger:
+		exp, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(o.Endpoint)))
+	case kindZipkin:
+		exp, err = zipkin.New(o.Endpoint)
+	case kindOTLPGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(o.Endpoint)}
+		if o.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(o.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(o.Headers))
+		}
+		exp, err = otlptracegrpc.New(context.Background(), opts...)
+	case kindOTLPHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(o.Endpoint)}
+		if o.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(o.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(o.Headers))
+		}
+		exp, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, nil, errors.Errorf("unknown batcher: %s", o.Batcher)
+	}
+	return exp, nil, err
+}
+
+// k8s 的 downward API 注入的环境变量
+var k8sEnvs = map[string]attribute.Key{
+	"POD_NAME":      semconv.K8SPodNameKey,
+	"POD_NAMESPACE": semconv.K8SNamespaceNameKey,
+	"NODE_NAME":     semconv.K8SNodeNameKey,
+}
+
+func newResource(o Options) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceName(o.Name)}
+	if o.Version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(o.Version))
+	}
+	if o.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(o.Environment))
+	}
+	for env, key := range k8sEnvs {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, key.String(v))
+		}
+	}
+	for k, v := range o.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	// OTEL_RESOURCE_ATTRIBUTES 中的属性优先
+	return resource.New(context.Background(),
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(attrs...),
+		resource.WithHost(),
+		resource.WithProcessPID(),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
 }
@@ -6,9 +6,64 @@ package trace
 
 const TraceName = "chaos"
 
+// 导出方式
+const (
+	kindJaeger   = "jaeger"
+	kindZipkin   = "zipkin"
+	kindOTLPGRPC = "otlpgrpc"
+	kindOTLPHTTP = "otlphttp"
+	kindStdout   = "stdout"
+	kindFile     = "file"
+)
+
+// 采样方式
+const (
+	SamplerAlways      = "always"
+	SamplerNever       = "never"
+	SamplerRatio       = "ratio"
+	SamplerRateLimited = "rate-limited"
+)
+
 type Options struct {
-	Name     string  `json:"name"`
-	Endpoint string  `json:"endpoint"`
-	Sampler  float64 `json:"sampler"`
-	Batcher  string  `json:"batcher"`
+	Name string `json:"name"`
+	// Endpoint is the collector address, or the file path of the file batcher
+	Endpoint string `json:"endpoint"`
+	// Sampler is the ratio of the ratio sampler
+	Sampler float64 `json:"sampler"`
+	// Batcher is one of jaeger, zipkin, otlpgrpc, otlphttp, stdout and file
+	Batcher string `json:"batcher"`
+
+	// Insecure exports to the otlp collector without tls
+	Insecure bool `json:"insecure"`
+	// Headers are sent with the otlp requests, e.g. the auth token of the collector
+	Headers map[string]string `json:"headers"`
+
+	Version     string `json:"version"`
+	Environment string `json:"environment"`
+	// Attributes are the extra resource attributes
+	Attributes map[string]string `json:"attributes"`
+
+	// SamplerType is one of always, never, ratio and rate-limited, ratio by default
+	SamplerType string `json:"sampler-type"`
+	// RateLimit is the traces per second of the rate-limited sampler
+	RateLimit float64 `json:"rate-limit"`
+	// Rules sample the routes differently, the first matched rule wins
+	Rules []SamplerRule `json:"rules"`
+
+	// Propagators are the header formats extracted and injected, one of tracecontext, baggage,
+	// b3, b3multi and jaeger, tracecontext and baggage by default
+	Propagators []string `json:"propagators"`
+
+	// TailSampling also keeps the error and slow traces which are not sampled, nil disables it
+	TailSampling *TailSamplingOptions `json:"tail-sampling"`
+}
+
+// SamplerRule samples the root spans of the route, the child spans follow their parent.
+type SamplerRule struct {
+	// Route matches the span name or the http.route attribute, e.g. "/api/v1/users", "/healthz*",
+	// "helloworld.Greeter/*", a trailing "*" matches the prefix
+	Route     string  `json:"route"`
+	Type      string  `json:"type"`
+	Ratio     float64 `json:"ratio"`
+	RateLimit float64 `json:"rate-limit"`
 }
@@ -0,0 +1,61 @@
+package trace
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// 传播格式, 与 OTEL_PROPAGATORS 的取值一致
+const (
+	// PropagatorTraceContext is the W3C traceparent and tracestate headers
+	PropagatorTraceContext = "tracecontext"
+	// PropagatorBaggage is the W3C baggage header
+	PropagatorBaggage = "baggage"
+	// PropagatorB3 is the single b3 header
+	PropagatorB3 = "b3"
+	// PropagatorB3Multi is the X-B3-* headers
+	PropagatorB3Multi = "b3multi"
+	// PropagatorJaeger is the uber-trace-id and uberctx-* headers
+	PropagatorJaeger = "jaeger"
+)
+
+var defaultPropagators = []string{PropagatorTraceContext, PropagatorBaggage}
+
+// NewPropagator creates the composite propagator of the names, tracecontext and baggage by default.
+// All of them are extracted and injected, so the services emitting different headers can talk to each other,
+// e.g. []string{"tracecontext", "baggage", "b3multi"} keeps the trace of the legacy zipkin services.
+func NewPropagator(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = defaultPropagators
+	}
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		case PropagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		default:
+			return nil, errors.Errorf("unknown propagator: %s", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
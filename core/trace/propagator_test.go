@@ -0,0 +1,193 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	fixtureTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	fixtureSpanID  = "00f067aa0ba902b7"
+)
+
+func fixtureSpanContext(t *testing.T) trace.SpanContext {
+	t.Helper()
+	tid, err := trace.TraceIDFromHex(fixtureTraceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, err := trace.SpanIDFromHex(fixtureSpanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+}
+
+func TestPropagatorExtract(t *testing.T) {
+	all := []string{PropagatorTraceContext, PropagatorBaggage, PropagatorB3, PropagatorB3Multi, PropagatorJaeger}
+	tests := []struct {
+		name        string
+		propagators []string
+		headers     map[string]string
+		sampled     bool
+	}{
+		{
+			name:        "traceparent",
+			propagators: []string{PropagatorTraceContext},
+			headers:     map[string]string{"traceparent": "00-" + fixtureTraceID + "-" + fixtureSpanID + "-01"},
+			sampled:     true,
+		},
+		{
+			name:        "b3 single",
+			propagators: []string{PropagatorB3},
+			headers:     map[string]string{"b3": fixtureTraceID + "-" + fixtureSpanID + "-1"},
+			sampled:     true,
+		},
+		{
+			name:        "b3 multi",
+			propagators: []string{PropagatorB3Multi},
+			headers: map[string]string{
+				"X-B3-TraceId": fixtureTraceID,
+				"X-B3-SpanId":  fixtureSpanID,
+				"X-B3-Sampled": "1",
+			},
+			sampled: true,
+		},
+		{
+			name:        "b3 multi not sampled",
+			propagators: []string{PropagatorB3Multi},
+			headers: map[string]string{
+				"X-B3-TraceId": fixtureTraceID,
+				"X-B3-SpanId":  fixtureSpanID,
+				"X-B3-Sampled": "0",
+			},
+			sampled: false,
+		},
+		{
+			name:        "uber-trace-id",
+			propagators: []string{PropagatorJaeger},
+			headers:     map[string]string{"uber-trace-id": fixtureTraceID + ":" + fixtureSpanID + ":0:1"},
+			sampled:     true,
+		},
+		{
+			name:        "b3 by the full chain",
+			propagators: all,
+			headers:     map[string]string{"b3": fixtureTraceID + "-" + fixtureSpanID + "-1"},
+			sampled:     true,
+		},
+		{
+			name:        "uber-trace-id by the full chain",
+			propagators: all,
+			headers:     map[string]string{"uber-trace-id": fixtureTraceID + ":" + fixtureSpanID + ":0:1"},
+			sampled:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPropagator(tt.propagators)
+			if err != nil {
+				t.Fatalf("NewPropagator: %v", err)
+			}
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			sc := trace.SpanContextFromContext(p.Extract(context.Background(), propagation.HeaderCarrier(h)))
+			if !sc.IsValid() || !sc.IsRemote() {
+				t.Fatalf("extracted span context %v is not a valid remote one", sc)
+			}
+			if sc.TraceID().String() != fixtureTraceID || sc.SpanID().String() != fixtureSpanID {
+				t.Errorf("extracted %s/%s, want %s/%s", sc.TraceID(), sc.SpanID(), fixtureTraceID, fixtureSpanID)
+			}
+			if sc.IsSampled() != tt.sampled {
+				t.Errorf("sampled = %v, want %v", sc.IsSampled(), tt.sampled)
+			}
+		})
+	}
+}
+
+func TestPropagatorInject(t *testing.T) {
+	traceparent := "00-" + fixtureTraceID + "-" + fixtureSpanID + "-01"
+	tests := []struct {
+		name        string
+		propagators []string
+		want        http.Header
+	}{
+		{
+			name: "default",
+			want: http.Header{"Traceparent": {traceparent}, "Baggage": {"tenant_id=acme"}},
+		},
+		{
+			name:        "b3 single",
+			propagators: []string{PropagatorB3},
+			want:        http.Header{"B3": {fixtureTraceID + "-" + fixtureSpanID + "-1"}},
+		},
+		{
+			name:        "b3 multi",
+			propagators: []string{PropagatorB3Multi},
+			want: http.Header{
+				"X-B3-Traceid": {fixtureTraceID},
+				"X-B3-Spanid":  {fixtureSpanID},
+				"X-B3-Sampled": {"1"},
+			},
+		},
+		{
+			name:        "jaeger",
+			propagators: []string{PropagatorJaeger},
+			want:        http.Header{"Uber-Trace-Id": {fixtureTraceID + ":" + fixtureSpanID + ":0:1"}},
+		},
+		{
+			name:        "tracecontext and b3 multi",
+			propagators: []string{PropagatorTraceContext, PropagatorB3Multi},
+			want: http.Header{
+				"Traceparent":  {traceparent},
+				"X-B3-Traceid": {fixtureTraceID},
+				"X-B3-Spanid":  {fixtureSpanID},
+				"X-B3-Sampled": {"1"},
+			},
+		},
+	}
+
+	member, err := baggage.NewMember("tenant_id", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := baggage.New(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), fixtureSpanContext(t))
+	ctx = baggage.ContextWithBaggage(ctx, b)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPropagator(tt.propagators)
+			if err != nil {
+				t.Fatalf("NewPropagator: %v", err)
+			}
+			h := http.Header{}
+			p.Inject(ctx, propagation.HeaderCarrier(h))
+			if !reflect.DeepEqual(h, tt.want) {
+				t.Errorf("injected headers = %v, want %v", h, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagatorUnknown(t *testing.T) {
+	if _, err := NewPropagator([]string{PropagatorTraceContext, "w3c"}); err == nil {
+		t.Error("expected an error for the unknown propagator")
+	}
+}
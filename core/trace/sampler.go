@@ -0,0 +1,122 @@
+package trace
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// newSampler creates the sampler of the options, the root spans are sampled by the rules
+// and the sampler type, the child spans follow their parent.
+func newSampler(o Options) (sdktrace.Sampler, error) {
+	root, err := baseSampler(o.SamplerType, o.Sampler, o.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.Rules) > 0 {
+		rs := &routeSampler{fallback: root}
+		for _, r := range o.Rules {
+			s, err := baseSampler(r.Type, r.Ratio, r.RateLimit)
+			if err != nil {
+				return nil, errors.Errorf("sampler rule %s: %v", r.Route, err)
+			}
+			rs.rules = append(rs.rules, routeRule{route: r.Route, sampler: s})
+		}
+		root = rs
+	}
+	return sdktrace.ParentBased(root), nil
+}
+
+func baseSampler(typ string, ratio, limit float64) (sdktrace.Sampler, error) {
+	switch typ {
+	case SamplerAlways:
+		return sdktrace.AlwaysSample(), nil
+	case SamplerNever:
+		return sdktrace.NeverSample(), nil
+	case SamplerRatio, "":
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case SamplerRateLimited:
+		if limit <= 0 {
+			return nil, errors.New("rate-limited sampler requires a positive rate limit")
+		}
+		return newRateLimitedSampler(limit), nil
+	default:
+		return nil, errors.Errorf("unknown sampler type: %s", typ)
+	}
+}
+
+// rateLimitedSampler samples at most limit traces per second.
+type rateLimitedSampler struct {
+	limiter     *rate.Limiter
+	description string
+}
+
+func newRateLimitedSampler(limit float64) *rateLimitedSampler {
+	burst := int(limit)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedSampler{
+		limiter:     rate.NewLimiter(rate.Limit(limit), burst),
+		description: fmt.Sprintf("RateLimitedSampler{%g}", limit),
+	}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.limiter.Allow() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{Decision: decision, Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState()}
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return s.description
+}
+
+type routeRule struct {
+	route   string
+	sampler sdktrace.Sampler
+}
+
+func (r routeRule) match(route string) bool {
+	if strings.HasSuffix(r.route, "*") {
+		return strings.HasPrefix(route, strings.TrimSuffix(r.route, "*"))
+	}
+	return route == r.route
+}
+
+// routeSampler samples by the first rule matching the span name or the http.route attribute.
+type routeSampler struct {
+	rules    []routeRule
+	fallback sdktrace.Sampler
+}
+
+func (s *routeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	routes := []string{p.Name}
+	for _, attr := range p.Attributes {
+		if attr.Key == httpRouteKey && attr.Value.Type() == attribute.STRING {
+			routes = append(routes, attr.Value.AsString())
+		}
+	}
+	for _, r := range s.rules {
+		for _, route := range routes {
+			if r.match(route) {
+				return r.sampler.ShouldSample(p)
+			}
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *routeSampler) Description() string {
+	return fmt.Sprintf("RouteSampler{rules:%d,fallback:%s}", len(s.rules), s.fallback.Description())
+}
+
+const httpRouteKey = attribute.Key("http.route")
@@ -0,0 +1,236 @@
+package trace
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chaos-ma/chaos/core/metric"
+)
+
+// DecisionKey is the span attribute of the sampling decision.
+const DecisionKey = attribute.Key("sampling.decision")
+
+// Sampling decisions.
+const (
+	// DecisionSampled is sampled by the head sampler
+	DecisionSampled = "sampled"
+	// DecisionError is kept because a span of the trace failed
+	DecisionError = "error"
+	// DecisionSlow is kept because the local root span is slower than the threshold
+	DecisionSlow = "slow"
+	// DecisionDropped is dropped after the local root span ends
+	DecisionDropped = "dropped"
+	// DecisionEvicted is dropped because the buffer is full
+	DecisionEvicted = "evicted"
+)
+
+// TailSamplingOptions keeps the error and slow traces which are not sampled by the head sampler.
+//
+// The decision is local: the propagated trace flags stay unsampled, so the downstream services drop
+// their spans of the traces kept here, and a kept trace only has the spans of this process.
+// Use the head sampler or the tail sampling of a collector for complete traces.
+type TailSamplingOptions struct {
+	// SlowThreshold keeps the traces whose local root span is slower, 0 disables it
+	SlowThreshold time.Duration `json:"slow-threshold"`
+	// MaxTraces is the buffered traces at most, the oldest one is evicted, 10000 by default
+	MaxTraces int `json:"max-traces"`
+	// MaxSpansPerTrace is the buffered spans of a trace at most, the later ones are dropped, 1000 by default
+	MaxSpansPerTrace int `json:"max-spans-per-trace"`
+	// Registry records the decisions, metric.DefaultRegistry() by default
+	Registry metric.Registry `json:"-"`
+}
+
+// NewTailSampler records the spans dropped by s without sampling them, so that the tail processor
+// can still keep them.
+func NewTailSampler(s sdktrace.Sampler) sdktrace.Sampler {
+	return tailSampler{Sampler: s}
+}
+
+type tailSampler struct {
+	sdktrace.Sampler
+}
+
+func (s tailSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	r := s.Sampler.ShouldSample(p)
+	if r.Decision == sdktrace.Drop {
+		r.Decision = sdktrace.RecordOnly
+	}
+	return r
+}
+
+func (s tailSampler) Description() string {
+	return "TailSampler{" + s.Sampler.Description() + "}"
+}
+
+// tailProcessor buffers the recorded but not sampled spans per trace, and decides when the local root span ends.
+type tailProcessor struct {
+	next sdktrace.SpanProcessor
+	opts TailSamplingOptions
+
+	decisions metric.CounterVec
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*list.Element
+	order  *list.List
+	// 已经保留的 trace, 之后结束的 span 也保留
+	kept     map[trace.TraceID]string
+	keptRing []trace.TraceID
+	keptNext int
+}
+
+type tailTrace struct {
+	id     trace.TraceID
+	spans  []sdktrace.ReadOnlySpan
+	failed bool
+}
+
+// NewTailProcessor creates the processor which forwards the sampled spans to next, and the spans of
+// the unsampled traces if the trace fails or its local root span is slow, use it with NewTailSampler.
+func NewTailProcessor(next sdktrace.SpanProcessor, opts TailSamplingOptions) sdktrace.SpanProcessor {
+	if opts.MaxTraces <= 0 {
+		opts.MaxTraces = 10000
+	}
+	if opts.MaxSpansPerTrace <= 0 {
+		opts.MaxSpansPerTrace = 1000
+	}
+	if opts.Registry == nil {
+		opts.Registry = metric.DefaultRegistry()
+	}
+	return &tailProcessor{
+		next: next,
+		opts: opts,
+		decisions: opts.Registry.NewCounterVec(&metric.CounterVecOpts{
+			Namespace: "trace",
+			Subsystem: "tail_sampling",
+			Name:      "decisions_total",
+			Help:      "trace tail sampling decisions count.",
+			Labels:    []string{"decision"},
+		}),
+		traces:   make(map[trace.TraceID]*list.Element),
+		order:    list.New(),
+		kept:     make(map[trace.TraceID]string),
+		keptRing: make([]trace.TraceID, opts.MaxTraces),
+	}
+}
+
+func (p *tailProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *tailProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	sc := s.SpanContext()
+	if sc.IsSampled() {
+		p.next.OnEnd(decidedSpan{ReadOnlySpan: s, decision: DecisionSampled})
+		return
+	}
+
+	p.mu.Lock()
+	if decision, ok := p.kept[sc.TraceID()]; ok {
+		p.mu.Unlock()
+		p.next.OnEnd(decidedSpan{ReadOnlySpan: s, decision: decision})
+		return
+	}
+
+	t := p.trace(sc.TraceID())
+	if len(t.spans) < p.opts.MaxSpansPerTrace {
+		t.spans = append(t.spans, s)
+	}
+	if s.Status().Code == codes.Error {
+		t.failed = true
+	}
+
+	// 本进程内的根 span 结束时决定是否保留
+	if parent := s.Parent(); parent.IsValid() && !parent.IsRemote() {
+		p.mu.Unlock()
+		return
+	}
+	p.remove(t.id)
+	decision := DecisionDropped
+	switch {
+	case t.failed:
+		decision = DecisionError
+	case p.opts.SlowThreshold > 0 && s.EndTime().Sub(s.StartTime()) >= p.opts.SlowThreshold:
+		decision = DecisionSlow
+	}
+	if decision != DecisionDropped {
+		p.keep(t.id, decision)
+	}
+	p.mu.Unlock()
+
+	p.decisions.Inc(decision)
+	if decision == DecisionDropped {
+		return
+	}
+	for _, span := range t.spans {
+		p.next.OnEnd(decidedSpan{ReadOnlySpan: span, decision: decision})
+	}
+}
+
+// trace returns the buffered trace of id, the oldest trace is evicted if the buffer is full.
+func (p *tailProcessor) trace(id trace.TraceID) *tailTrace {
+	if e, ok := p.traces[id]; ok {
+		return e.Value.(*tailTrace)
+	}
+	if p.order.Len() >= p.opts.MaxTraces {
+		oldest := p.order.Front().Value.(*tailTrace)
+		p.remove(oldest.id)
+		p.decisions.Inc(DecisionEvicted)
+	}
+	t := &tailTrace{id: id}
+	p.traces[id] = p.order.PushBack(t)
+	return t
+}
+
+func (p *tailProcessor) remove(id trace.TraceID) {
+	if e, ok := p.traces[id]; ok {
+		p.order.Remove(e)
+		delete(p.traces, id)
+	}
+}
+
+// keep remembers the last MaxTraces kept traces.
+func (p *tailProcessor) keep(id trace.TraceID, decision string) {
+	if old := p.keptRing[p.keptNext]; old.IsValid() {
+		delete(p.kept, old)
+	}
+	p.keptRing[p.keptNext] = id
+	p.keptNext = (p.keptNext + 1) % len(p.keptRing)
+	p.kept[id] = decision
+}
+
+func (p *tailProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.traces = make(map[trace.TraceID]*list.Element)
+	p.order.Init()
+	p.mu.Unlock()
+	return p.next.Shutdown(ctx)
+}
+
+func (p *tailProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// decidedSpan is the kept span with the sampled flag and the decision attribute,
+// the batch span processor only exports the sampled spans.
+type decidedSpan struct {
+	sdktrace.ReadOnlySpan
+	decision string
+}
+
+func (s decidedSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
+
+func (s decidedSpan) Attributes() []attribute.KeyValue {
+	attrs := s.ReadOnlySpan.Attributes()
+	out := make([]attribute.KeyValue, 0, len(attrs)+1)
+	return append(append(out, attrs...), DecisionKey.String(s.decision))
+}
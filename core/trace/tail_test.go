@@ -0,0 +1,152 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chaos-ma/chaos/core/metric"
+)
+
+type tailFixture struct {
+	tracer   trace.Tracer
+	recorder *tracetest.SpanRecorder
+	reg      *prom.Registry
+}
+
+func newTailFixture(t *testing.T, sampler sdktrace.Sampler, opts TailSamplingOptions) *tailFixture {
+	t.Helper()
+	f := &tailFixture{recorder: tracetest.NewSpanRecorder(), reg: prom.NewRegistry()}
+	opts.Registry = metric.NewRegistry(metric.WithPrometheus(f.reg))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(NewTailSampler(sampler)),
+		sdktrace.WithSpanProcessor(NewTailProcessor(f.recorder, opts)),
+	)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	f.tracer = tp.Tracer("tail")
+	return f
+}
+
+// exported returns the decisions of the exported spans of trace id by span name.
+func (f *tailFixture) exported(t *testing.T, id trace.TraceID) map[string]string {
+	t.Helper()
+	spans := map[string]string{}
+	for _, s := range f.recorder.Ended() {
+		if s.SpanContext().TraceID() != id {
+			continue
+		}
+		if !s.SpanContext().IsSampled() {
+			t.Errorf("span %s is exported without the sampled flag", s.Name())
+		}
+		decision := ""
+		for _, kv := range s.Attributes() {
+			if kv.Key == DecisionKey {
+				decision = kv.Value.AsString()
+			}
+		}
+		spans[s.Name()] = decision
+	}
+	return spans
+}
+
+func (f *tailFixture) decisions(t *testing.T, decision string) float64 {
+	t.Helper()
+	families, err := f.reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "trace_tail_sampling_decisions_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "decision" && l.GetValue() == decision {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestTailKeepsErrorTrace(t *testing.T) {
+	f := newTailFixture(t, sdktrace.NeverSample(), TailSamplingOptions{})
+	ctx, root := f.tracer.Start(context.Background(), "root")
+	_, child := f.tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	root.End()
+
+	got := f.exported(t, root.SpanContext().TraceID())
+	if len(got) != 2 || got["root"] != DecisionError || got["child"] != DecisionError {
+		t.Errorf("exported = %v, want root and child with decision %s", got, DecisionError)
+	}
+	if n := f.decisions(t, DecisionError); n != 1 {
+		t.Errorf("error decisions = %v, want 1", n)
+	}
+}
+
+func TestTailKeepsSlowRoot(t *testing.T) {
+	f := newTailFixture(t, sdktrace.NeverSample(), TailSamplingOptions{SlowThreshold: 10 * time.Millisecond})
+	start := time.Now()
+
+	_, fast := f.tracer.Start(context.Background(), "fast", trace.WithTimestamp(start))
+	fast.End(trace.WithTimestamp(start.Add(time.Millisecond)))
+	_, slow := f.tracer.Start(context.Background(), "slow", trace.WithTimestamp(start))
+	slow.End(trace.WithTimestamp(start.Add(20 * time.Millisecond)))
+
+	if got := f.exported(t, fast.SpanContext().TraceID()); len(got) != 0 {
+		t.Errorf("the fast trace is exported: %v", got)
+	}
+	if got := f.exported(t, slow.SpanContext().TraceID()); got["slow"] != DecisionSlow {
+		t.Errorf("exported = %v, want the slow root with decision %s", got, DecisionSlow)
+	}
+	if n := f.decisions(t, DecisionDropped); n != 1 {
+		t.Errorf("dropped decisions = %v, want 1", n)
+	}
+}
+
+func TestTailEvictsOldestTrace(t *testing.T) {
+	f := newTailFixture(t, sdktrace.NeverSample(), TailSamplingOptions{MaxTraces: 2})
+
+	var roots []trace.Span
+	for i := 0; i < 3; i++ {
+		ctx, root := f.tracer.Start(context.Background(), "root")
+		_, child := f.tracer.Start(ctx, "child")
+		child.End()
+		roots = append(roots, root)
+	}
+	if n := f.decisions(t, DecisionEvicted); n != 1 {
+		t.Errorf("evicted decisions = %v, want 1", n)
+	}
+
+	// 第一个 trace 的子 span 已被驱逐, 只剩下根 span
+	roots[0].SetStatus(codes.Error, "boom")
+	roots[0].End()
+	if got := f.exported(t, roots[0].SpanContext().TraceID()); len(got) != 1 || got["root"] != DecisionError {
+		t.Errorf("exported = %v, want only the root of the evicted trace", got)
+	}
+
+	roots[2].SetStatus(codes.Error, "boom")
+	roots[2].End()
+	if got := f.exported(t, roots[2].SpanContext().TraceID()); len(got) != 2 {
+		t.Errorf("exported = %v, want root and child of the buffered trace", got)
+	}
+}
+
+func TestTailMarksHeadSampled(t *testing.T) {
+	f := newTailFixture(t, sdktrace.AlwaysSample(), TailSamplingOptions{})
+	_, root := f.tracer.Start(context.Background(), "root")
+	root.End()
+
+	if got := f.exported(t, root.SpanContext().TraceID()); got["root"] != DecisionSampled {
+		t.Errorf("exported = %v, want the root with decision %s", got, DecisionSampled)
+	}
+}
@@ -56,8 +56,9 @@ func New(opts *Options) *Logger {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
+	level := zap.NewAtomicLevelAt(zapLevel)
 	loggerConfig := &zap.Config{
-		Level:             zap.NewAtomicLevelAt(zapLevel),
+		Level:             level,
 		Development:       opts.Development,
 		DisableCaller:     opts.DisableCaller,
 		DisableStacktrace: opts.DisableStacktrace,
@@ -80,10 +81,12 @@ func New(opts *Options) *Logger {
 		Logger: l,
 		//有时我们稍微封装了一下记录日志的方法，但是我们希望输出的文件名和行号是调用封装函数的位置。这时可以使用zap.AddCallerSkip(skip int)向上跳 1 层：
 		skipCaller:       l.WithOptions(zap.AddCallerSkip(1)),
-		minLevel:         zapLevel,
+		level:            level,
+		minLevel:         zap.NewAtomicLevelAt(zapLevel),
 		errorStatusLevel: zap.ErrorLevel,
 		caller:           true,
 		withTraceID:      true,
+		baggageKeys:      opts.BaggageKeys,
 		//stackTrace:       true,
 	}
 	zap.RedirectStdLog(l)
@@ -91,6 +94,22 @@ func New(opts *Options) *Logger {
 	return logger
 }
 
+// SetLevel changes the level of the std logger at runtime, e.g. "debug".
+func SetLevel(level string) error {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	std.level.SetLevel(lvl)
+	std.minLevel.SetLevel(lvl)
+	return nil
+}
+
+// GetLevel returns the level of the std logger.
+func GetLevel() string {
+	return std.level.String()
+}
+
 func ZapLogger() *zap.Logger {
 	return std.Logger
 }
@@ -31,6 +31,8 @@ type Options struct {
 	Development       bool     `json:"development"        mapstructure:"development"`
 	EnableTraceID     bool     `json:"enable-trace-id"    mapstructure:"enable-trace-id"`    //是否开启traceID
 	EnableTraceStack  bool     `json:"enable-trace-stack" mapstructure:"enable-trace-stack"` //是否开启traceStack
+	// BaggageKeys are the baggage members logged by the *C functions
+	BaggageKeys []string `json:"baggage-keys" mapstructure:"baggage-keys"`
 }
 
 func NewOptions() *Options {
@@ -43,6 +45,8 @@ func NewOptions() *Options {
 		Development:       false,
 		OutputPaths:       []string{"stdout"},
 		ErrorOutputPaths:  []string{"stderr"},
+		// 与 core/baggage 的 typed keys 一致
+		BaggageKeys: []string{"tenant_id", "user_id", "canary"},
 	}
 }
 
@@ -119,7 +123,7 @@ type Option func(l *Logger)
 
 func WithMinLevel(lvl zapcore.Level) Option {
 	return func(l *Logger) {
-		l.minLevel = lvl
+		l.minLevel = zap.NewAtomicLevelAt(lvl)
 	}
 }
 
@@ -147,3 +151,10 @@ func WithTraceIDField(on bool) Option {
 		l.withTraceID = on
 	}
 }
+
+// WithBaggageKeys logs the baggage members of the keys in the *C functions.
+func WithBaggageKeys(keys ...string) Option {
+	return func(l *Logger) {
+		l.baggageKeys = keys
+	}
+}
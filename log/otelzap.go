@@ -17,11 +17,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/uptrace/opentelemetry-go-extra/otelutil"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/codes"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	baggage2 "github.com/chaos-ma/chaos/core/baggage"
 )
 
 const numAttr = 5
@@ -43,8 +46,11 @@ type Logger struct {
 	skipCaller *zap.Logger
 
 	withTraceID bool
+	baggageKeys []string
 
-	minLevel         zapcore.Level
+	level zap.AtomicLevel
+	// minLevel 以下的日志不记录到 span, SetLevel 同时修改它
+	minLevel         zap.AtomicLevel
 	errorStatusLevel zapcore.Level
 
 	caller     bool
@@ -190,22 +196,28 @@ func (l *Logger) FatalfContext(ctx context.Context, format string, v ...interfac
 }
 
 func (l *Logger) logFields(ctx context.Context, lvl zapcore.Level, msg string, fields []zapcore.Field) []zapcore.Field {
-	if lvl < l.minLevel {
+	if !l.minLevel.Enabled(lvl) {
 		return fields
 	}
 
-	switch ctx.(type) {
+	if username, ok := UsernameFromContext(ctx); ok {
+		fields = append(fields, zap.String(KeyUsername, username))
+	}
+	var requestID string
+	switch c := ctx.(type) {
 	case *gin.Context:
-		requestID, _ := ctx.Value(KeyRequestID).(string)
-		username, _ := ctx.Value(KeyUsername).(string)
-		if requestID == "" {
-			fields = append(fields, zap.String(KeyRequestID, requestID))
-		}
-		if username == "" {
-			fields = append(fields, zap.String(KeyUsername, username))
-		}
-		ctx = ctx.(*gin.Context).Request.Context()
+		requestID, _ = c.Value(KeyRequestID).(string)
+		ctx = c.Request.Context()
 	}
+	if requestID == "" {
+		requestID, _ = RequestIDFromContext(ctx)
+	}
+	if requestID != "" {
+		fields = append(fields, zap.String(KeyRequestID, requestID))
+	}
+	l.eachBaggage(ctx, func(key, value string) {
+		fields = append(fields, zap.String(key, value))
+	})
 
 	span := trace.SpanFromContext(ctx)
 	if !span.IsRecording() {
@@ -240,6 +252,22 @@ func (l *Logger) logFields(ctx context.Context, lvl zapcore.Level, msg string, f
 	return fields
 }
 
+// eachBaggage calls fn with the baggage members of the logged keys.
+func (l *Logger) eachBaggage(ctx context.Context, fn func(key, value string)) {
+	if len(l.baggageKeys) == 0 {
+		return
+	}
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		return
+	}
+	for _, key := range l.baggageKeys {
+		if value := baggage2.Value(b.Member(key)); value != "" {
+			fn(key, value)
+		}
+	}
+}
+
 func (l *Logger) log(span trace.Span, lvl zapcore.Level, msg string, attrs []attribute.KeyValue) {
 	attrs = append(attrs, logSeverityKey.String(levelString(lvl)))
 	attrs = append(attrs, logMessageKey.String(msg))
@@ -421,7 +449,7 @@ func (s *SugaredLogger) FatalfContext(ctx context.Context, template string, args
 func (s *SugaredLogger) logArgs(
 	ctx context.Context, lvl zapcore.Level, template string, args []interface{},
 ) {
-	if lvl < s.l.minLevel {
+	if !s.l.minLevel.Enabled(lvl) {
 		return
 	}
 	span := trace.SpanFromContext(ctx)
@@ -487,9 +515,15 @@ func (s *SugaredLogger) FatalwContext(
 func (s *SugaredLogger) logKVs(
 	ctx context.Context, lvl zapcore.Level, msg string, kvs []interface{},
 ) []interface{} {
-	if lvl < s.l.minLevel {
+	if !s.l.minLevel.Enabled(lvl) {
 		return kvs
 	}
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		kvs = append(kvs, KeyRequestID, requestID)
+	}
+	s.l.eachBaggage(ctx, func(key, value string) {
+		kvs = append(kvs, key, value)
+	})
 	span := trace.SpanFromContext(ctx)
 	if !span.IsRecording() {
 		return kvs
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the http header and grpc metadata key of the request id.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen 限制上游传入的 request id 长度, 避免日志被撑爆
+const maxRequestIDLen = 128
+
+// ValidRequestID reports whether the request id from upstream can be logged, it is at most 128
+// printable ASCII characters without spaces, the http middleware and the grpc interceptor share it.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+type requestIDKey struct{}
+
+// NewRequestIDContext creates a new context with the request id attached,
+// the *C log functions log it next to the trace id.
+func NewRequestIDContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request id in ctx if it exists, the request context of *gin.Context is used.
+func RequestIDFromContext(ctx context.Context) (requestID string, ok bool) {
+	if c, isGin := ctx.(*gin.Context); isGin && c != nil && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	if ctx == nil {
+		return "", false
+	}
+	requestID, ok = ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
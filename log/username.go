@@ -0,0 +1,34 @@
+package log
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+type usernameKey struct{}
+
+// NewUsernameContext creates a new context with the authenticated username attached,
+// the grpc auth interceptor uses it, the *C log functions log it.
+func NewUsernameContext(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, usernameKey{}, username)
+}
+
+// UsernameFromContext returns the authenticated username in ctx if it exists, it is KeyUsername set by the
+// http auth strategies for *gin.Context, so the helpers shared by http and grpc look it up once.
+func UsernameFromContext(ctx context.Context) (username string, ok bool) {
+	if c, isGin := ctx.(*gin.Context); isGin && c != nil {
+		if username = c.GetString(KeyUsername); username != "" {
+			return username, true
+		}
+		if c.Request == nil {
+			return "", false
+		}
+		ctx = c.Request.Context()
+	}
+	if ctx == nil {
+		return "", false
+	}
+	username, ok = ctx.Value(usernameKey{}).(string)
+	return username, ok && username != ""
+}
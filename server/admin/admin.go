@@ -0,0 +1,285 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/registry"
+	"github.com/chaos-ma/chaos/server"
+	"github.com/chaos-ma/chaos/server/health"
+	"github.com/chaos-ma/chaos/server/httpserver"
+	"github.com/chaos-ma/chaos/server/httpserver/pprof"
+	"github.com/chaos-ma/chaos/server/rpcserver"
+)
+
+// 构建信息, 通过 -ldflags "-X github.com/chaos-ma/chaos/server/admin.Version=v1.0.0" 设置
+var (
+	Version   string
+	GitCommit string
+	BuildTime string
+)
+
+// Option is admin server option.
+type Option func(s *Server)
+
+// WithAddress sets the listen address, "127.0.0.1:9090" by default.
+func WithAddress(address string) Option {
+	return func(s *Server) {
+		s.address = address
+	}
+}
+
+// WithGuard guards the admin routes except the probes, e.g. auth.NewBasicStrategy(compare).AuthFunc(),
+// the server only listens on a loopback address if no guard is set. With a guard the probes omit the
+// check details, which are served on /debug/health, /debug/ready and /debug/live behind the guard.
+func WithGuard(guard gin.HandlerFunc) Option {
+	return func(s *Server) {
+		s.guard = guard
+	}
+}
+
+// WithProfiling enables the pprof routes, true by default.
+func WithProfiling(enable bool) Option {
+	return func(s *Server) {
+		s.enableProfiling = enable
+	}
+}
+
+// WithHealth serves the probes of h.
+func WithHealth(h *health.Health) Option {
+	return func(s *Server) {
+		s.health = h
+	}
+}
+
+// WithMetricRegistry serves reg on /metrics instead of the default registry,
+// /metrics returns 404 for a metric.OTelRegistry which pushes the metrics by OTLP.
+func WithMetricRegistry(reg metric.Registry) Option {
+	return func(s *Server) {
+		s.metrics = reg
+	}
+}
+
+// WithRestServer shows the routes of the rest server.
+func WithRestServer(rest *httpserver.Server) Option {
+	return func(s *Server) {
+		s.rest = rest
+	}
+}
+
+// WithRPCServer shows the services of the rpc server.
+func WithRPCServer(rpc *rpcserver.Server) Option {
+	return func(s *Server) {
+		s.rpc = rpc
+	}
+}
+
+// WithDiscovery shows the instances of the services in discovery.
+func WithDiscovery(d registry.Discovery, services ...string) Option {
+	return func(s *Server) {
+		s.discovery = d
+		s.services = services
+	}
+}
+
+// Server is the admin server on a separate port, it serves pprof, metrics, probes and
+// the runtime state, keep the port away from the public network.
+type Server struct {
+	*gin.Engine
+	address         string
+	guard           gin.HandlerFunc
+	enableProfiling bool
+	health          *health.Health
+	metrics         metric.Registry
+	rest            *httpserver.Server
+	rpc             *rpcserver.Server
+	discovery       registry.Discovery
+	services        []string
+
+	server *http.Server
+}
+
+var _ server.Server = (*Server)(nil)
+
+// NewServer creates an admin server.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		Engine:          gin.New(),
+		address:         "127.0.0.1:9090",
+		enableProfiling: true,
+		metrics:         metric.DefaultRegistry(),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	s.Use(gin.Recovery())
+	s.server = &http.Server{Handler: s.Engine, ReadHeaderTimeout: 10 * time.Second}
+
+	g := s.Group("/")
+	if s.guard != nil {
+		g.Use(s.guard)
+	}
+	if s.health != nil {
+		if s.guard == nil {
+			s.health.RegisterRoutes(s.Engine, health.WithVerbose())
+		} else {
+			// 探针不经过 guard, 检查的错误详情只在 guard 之后展示
+			s.health.RegisterRoutes(s.Engine)
+			s.health.RegisterRoutes(g.Group("/debug"), health.WithVerbose())
+		}
+	}
+	if s.enableProfiling {
+		pprof.RouteRegister(g)
+	}
+	if _, ok := s.metrics.(*metric.OTelRegistry); ok {
+		log.Warnf("[admin] metrics are pushed by otlp, /metrics serves nothing")
+	}
+	g.GET("/metrics", gin.WrapH(s.metrics.Handler()))
+	g.GET("/buildinfo", s.buildInfo)
+	g.GET("/loglevel", getLogLevel)
+	g.PUT("/loglevel", setLogLevel)
+	g.GET("/routes", s.routes)
+	g.GET("/discovery", s.discoveryState)
+	g.GET("/selectors", func(c *gin.Context) {
+		c.JSON(http.StatusOK, rpcserver.SelectorState())
+	})
+
+	return s
+}
+
+// Start starts the admin server.
+func (s *Server) Start(ctx context.Context) error {
+	// 没有 guard 时只允许监听本地回环地址
+	if s.guard == nil && !isLoopback(s.address) {
+		return fmt.Errorf("admin server on %s must be guarded by WithGuard or listen on a loopback address", s.address)
+	}
+	lis, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	log.Infof("[admin] server is running on: %s", lis.Addr().String())
+	if err := s.server.Serve(lis); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Stop stops the admin server.
+func (s *Server) Stop(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Errorf("[admin] server shutdown error: %s", err)
+		return err
+	}
+	log.Info("[admin] server stopped")
+	return nil
+}
+
+// isLoopback reports whether address only listens on the loopback interface, "localhost" included.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) buildInfo(c *gin.Context) {
+	info := gin.H{
+		"version":    Version,
+		"git_commit": GitCommit,
+		"build_time": BuildTime,
+		"go_version": runtime.Version(),
+		"os_arch":    runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["path"] = bi.Path
+		if Version == "" {
+			info["version"] = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" && GitCommit == "" {
+				info["git_commit"] = setting.Value
+			}
+		}
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+func getLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": log.GetLevel()})
+}
+
+func setLogLevel(c *gin.Context) {
+	var req struct {
+		Level string `json:"level" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := log.SetLevel(req.Level); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Infof("[admin] log level changes to %s", req.Level)
+	c.JSON(http.StatusOK, gin.H{"level": log.GetLevel()})
+}
+
+type route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+func (s *Server) routes(c *gin.Context) {
+	resp := gin.H{}
+	if s.rest != nil {
+		routes := make([]route, 0)
+		for _, r := range s.rest.Routes() {
+			routes = append(routes, route{Method: r.Method, Path: r.Path, Handler: r.Handler})
+		}
+		resp["http"] = routes
+	}
+	if s.rpc != nil {
+		services := map[string][]string{}
+		for name, info := range s.rpc.GetServiceInfo() {
+			methods := make([]string, 0, len(info.Methods))
+			for _, m := range info.Methods {
+				methods = append(methods, m.Name)
+			}
+			services[name] = methods
+		}
+		resp["grpc"] = services
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) discoveryState(c *gin.Context) {
+	if s.discovery == nil {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+	resp := make(map[string]interface{}, len(s.services))
+	for _, name := range s.services {
+		instances, err := s.discovery.GetService(c.Request.Context(), name)
+		if err != nil {
+			resp[name] = gin.H{"error": err.Error()}
+			continue
+		}
+		resp[name] = instances
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/server/health"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func tokenGuard(c *gin.Context) {
+	if c.GetHeader("Authorization") != "Bearer admin" {
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+func serve(s *Server, method, path, body string, auth bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if auth {
+		req.Header.Set("Authorization", "Bearer admin")
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStartRefusesPublicAddressWithoutGuard(t *testing.T) {
+	for _, address := range []string{"0.0.0.0:0", ":0", "10.0.0.1:9090"} {
+		s := NewServer(WithAddress(address), WithMetricRegistry(metric.NewNopRegistry()))
+		if err := s.Start(context.Background()); err == nil {
+			t.Errorf("Start on %s without guard should fail", address)
+		}
+	}
+	for _, address := range []string{"127.0.0.1:9090", "localhost:9090", "[::1]:9090"} {
+		if !isLoopback(address) {
+			t.Errorf("%s should be loopback", address)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	h := health.New()
+	h.Register("db", func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.1:3306: refused") })
+	s := NewServer(WithGuard(tokenGuard), WithHealth(h), WithMetricRegistry(metric.NewNopRegistry()))
+
+	for _, path := range []string{"/buildinfo", "/loglevel", "/metrics", "/debug/health"} {
+		if rec := serve(s, http.MethodGet, path, "", false); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without auth = %d, want 401", path, rec.Code)
+		}
+	}
+
+	// 探针不需要认证, 也不展示错误详情
+	for _, path := range []string{"/health", "/ready?verbose", "/live"} {
+		rec := serve(s, http.MethodGet, path, "", false)
+		if rec.Code == http.StatusUnauthorized || strings.Contains(rec.Body.String(), "refused") {
+			t.Errorf("%s = %d %s, want the probe without details", path, rec.Code, rec.Body.String())
+		}
+	}
+	rec := serve(s, http.MethodGet, "/debug/health", "", true)
+	if !strings.Contains(rec.Body.String(), "refused") {
+		t.Errorf("/debug/health = %s, want the details", rec.Body.String())
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	s := NewServer(WithMetricRegistry(metric.NewNopRegistry()))
+	prev := log.GetLevel()
+	defer log.SetLevel(prev)
+
+	rec := serve(s, http.MethodPut, "/loglevel", `{"level":"debug"}`, false)
+	if rec.Code != http.StatusOK || log.GetLevel() != "debug" {
+		t.Fatalf("PUT /loglevel = %d %s, level %s", rec.Code, rec.Body.String(), log.GetLevel())
+	}
+	rec = serve(s, http.MethodGet, "/loglevel", "", false)
+	var resp struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Level != "debug" {
+		t.Errorf("GET /loglevel = %s, %v", rec.Body.String(), err)
+	}
+	for _, body := range []string{`{"level":"verbose"}`, `{}`, `{`} {
+		if rec := serve(s, http.MethodPut, "/loglevel", body, false); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT /loglevel %s = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestStopWhileStarting(t *testing.T) {
+	s := NewServer(WithAddress("127.0.0.1:0"), WithMetricRegistry(metric.NewNopRegistry()))
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Start(context.Background())
+	}()
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start does not return after Stop")
+	}
+}
@@ -0,0 +1,145 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+// DecisionFunc decides whether the request is allowed, the policy is the current one.
+type DecisionFunc func(ctx context.Context, policy *Policy, req Request) (bool, error)
+
+// DefaultDecision allows the request if the policy allows it.
+func DefaultDecision(_ context.Context, policy *Policy, req Request) (bool, error) {
+	if policy == nil {
+		return false, nil
+	}
+	return policy.Allowed(req), nil
+}
+
+// Option is authorizer option.
+type Option func(a *Authorizer)
+
+// WithPolicyFile loads the policy from file and reloads it when the file changes.
+func WithPolicyFile(file string, reloadInterval time.Duration) Option {
+	return func(a *Authorizer) {
+		a.file = file
+		a.reloadInterval = reloadInterval
+	}
+}
+
+// WithPolicy sets a static policy.
+func WithPolicy(p *Policy) Option {
+	return func(a *Authorizer) {
+		a.policy.Store(p)
+	}
+}
+
+// WithDecision replaces the default decision function.
+func WithDecision(decide DecisionFunc) Option {
+	return func(a *Authorizer) {
+		a.decide = decide
+	}
+}
+
+// WithSkip skips the authorization of the resources which skip returns true.
+func WithSkip(skip func(resource string) bool) Option {
+	return func(a *Authorizer) {
+		a.skip = skip
+	}
+}
+
+// Authorizer authorizes the requests of gin routes and grpc methods with the policy.
+type Authorizer struct {
+	policy atomic.Value
+	decide DecisionFunc
+	skip   func(resource string) bool
+
+	file           string
+	reloadInterval time.Duration
+	modTime        time.Time
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewAuthorizer creates an authorizer, the policy file is loaded at once.
+func NewAuthorizer(opts ...Option) (*Authorizer, error) {
+	a := &Authorizer{
+		decide: DefaultDecision,
+		done:   make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(a)
+	}
+	if a.file != "" {
+		if err := a.load(); err != nil {
+			return nil, err
+		}
+		if a.reloadInterval > 0 {
+			go a.watch()
+		}
+	}
+	return a, nil
+}
+
+// Policy returns the current policy.
+func (a *Authorizer) Policy() *Policy {
+	p, _ := a.policy.Load().(*Policy)
+	return p
+}
+
+// Authorize decides the request, the skipped resources are always allowed.
+func (a *Authorizer) Authorize(ctx context.Context, req Request) (bool, error) {
+	if a.skip != nil && a.skip(req.Resource) {
+		return true, nil
+	}
+	return a.decide(ctx, a.Policy(), req)
+}
+
+// Stop stops watching the policy file.
+func (a *Authorizer) Stop() {
+	a.once.Do(func() {
+		close(a.done)
+	})
+}
+
+func (a *Authorizer) watch() {
+	ticker := time.NewTicker(a.reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(a.file)
+			if err != nil || fi.ModTime().Equal(a.modTime) {
+				continue
+			}
+			if err := a.load(); err != nil {
+				log.Errorf("[authz] failed to reload policy, keep the old one: %s", err)
+				continue
+			}
+			log.Infof("[authz] policy reloaded from %s", a.file)
+		}
+	}
+}
+
+func (a *Authorizer) load() error {
+	fi, err := os.Stat(a.file)
+	if err != nil {
+		return errors.Wrapf(err, "stat policy file %s", a.file)
+	}
+	p, err := LoadPolicy(a.file)
+	if err != nil {
+		return err
+	}
+	a.modTime = fi.ModTime()
+	a.policy.Store(p)
+	return nil
+}
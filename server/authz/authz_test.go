@@ -0,0 +1,103 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const readerPolicy = `
+roles:
+  - name: reader
+    rules:
+      - resources: ["/v1/users/*"]
+        actions: ["GET"]
+subjects:
+  alice: [reader]
+`
+
+const writerPolicy = `
+roles:
+  - name: writer
+    rules:
+      - resources: ["/v1/users/*"]
+        actions: ["POST"]
+subjects:
+  alice: [writer]
+`
+
+func writePolicy(t *testing.T, file, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// 保证 mtime 变化, 文件系统的精度可能是秒
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthorizerReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	now := time.Now()
+	writePolicy(t, file, readerPolicy, now.Add(-time.Hour))
+
+	a, err := NewAuthorizer(WithPolicyFile(file, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+
+	get := Request{Subject: "alice", Resource: "/v1/users/1", Action: "GET"}
+	post := Request{Subject: "alice", Resource: "/v1/users/1", Action: "POST"}
+	allowed := func(req Request) bool {
+		ok, err := a.Authorize(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !allowed(get) || allowed(post) {
+		t.Fatal("the reader policy is not loaded")
+	}
+
+	// 无效的策略保留旧的
+	writePolicy(t, file, "subjects:\n  alice: [missing]\n", now.Add(-time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if !allowed(get) {
+		t.Fatal("the invalid policy replaced the old one")
+	}
+
+	writePolicy(t, file, writerPolicy, now)
+	deadline := time.Now().Add(time.Second)
+	for allowed(get) || !allowed(post) {
+		if time.Now().After(deadline) {
+			t.Fatal("the writer policy is not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewAuthorizerInvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, file, "{", time.Now())
+	if _, err := NewAuthorizer(WithPolicyFile(file, 0)); err == nil {
+		t.Error("an invalid policy file should fail")
+	}
+}
+
+func TestAuthorizerSkip(t *testing.T) {
+	a, err := NewAuthorizer(WithSkip(func(resource string) bool { return resource == "/ping" }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有策略时拒绝所有请求
+	if ok, _ := a.Authorize(context.Background(), Request{Resource: "/v1/users"}); ok {
+		t.Error("no policy should deny")
+	}
+	if ok, _ := a.Authorize(context.Background(), Request{Resource: "/ping"}); !ok {
+		t.Error("the skipped resource should be allowed")
+	}
+}
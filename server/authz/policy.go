@@ -0,0 +1,152 @@
+package authz
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// Wildcard matches any subject, resource or action.
+const Wildcard = "*"
+
+// Rule allows the actions on the resources, both of them support `*` wildcards,
+// e.g. resources: ["/v1/users/*", "/user.User/*"], actions: ["GET", "POST"].
+type Rule struct {
+	Resources []string `json:"resources" yaml:"resources"`
+	Actions   []string `json:"actions"   yaml:"actions"`
+}
+
+// Role is a named set of rules.
+type Role struct {
+	Name  string `json:"name"  yaml:"name"`
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Policy binds subjects to roles, the subject `*` applies to everyone.
+type Policy struct {
+	Roles    []Role              `json:"roles"    yaml:"roles"`
+	Subjects map[string][]string `json:"subjects" yaml:"subjects"`
+
+	roles map[string]*Role
+}
+
+// Request is the authorization request.
+type Request struct {
+	// Subject is the username of the caller
+	Subject string
+	// Resource is the gin route path or the grpc full method
+	Resource string
+	// Action is the http method, or ActionRPC for grpc
+	Action string
+}
+
+// ActionRPC is the action of grpc requests.
+const ActionRPC = "RPC"
+
+// LoadPolicy loads the policy from a json or yaml file.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, p)
+	default:
+		err = json.Unmarshal(data, p)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse policy file %s", file)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewPolicy creates a policy with roles and subject bindings.
+func NewPolicy(roles []Role, subjects map[string][]string) (*Policy, error) {
+	p := &Policy{Roles: roles, Subjects: subjects}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) compile() error {
+	p.roles = make(map[string]*Role, len(p.Roles))
+	for i := range p.Roles {
+		r := &p.Roles[i]
+		if r.Name == "" {
+			return errors.New("role name cannot be empty")
+		}
+		p.roles[r.Name] = r
+	}
+	for subject, roles := range p.Subjects {
+		for _, name := range roles {
+			if _, ok := p.roles[name]; !ok {
+				return errors.Errorf("subject %s is bound to unknown role %s", subject, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed checks whether any role of the subject allows the request.
+func (p *Policy) Allowed(req Request) bool {
+	for _, subject := range []string{req.Subject, Wildcard} {
+		if subject == "" {
+			continue
+		}
+		for _, name := range p.Subjects[subject] {
+			if p.roles[name].allows(req) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Role) allows(req Request) bool {
+	for _, rule := range r.Rules {
+		if matchAny(rule.Resources, req.Resource) && matchAny(rule.Actions, req.Action) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if match(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether s matches the pattern, `*` matches any sequence of characters.
+func match(pattern, s string) bool {
+	if !strings.Contains(pattern, Wildcard) {
+		return pattern == s
+	}
+	parts := strings.Split(pattern, Wildcard)
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i := 1; i < len(parts)-1; i++ {
+		idx := strings.Index(s, parts[i])
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(parts[i]):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
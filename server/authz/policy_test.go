@@ -0,0 +1,76 @@
+package authz
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"/v1/users", "/v1/users", true},
+		{"/v1/users", "/v1/users/1", false},
+		{"/v1/users/*", "/v1/users/1", true},
+		{"/v1/users/*", "/v1/users/1/orders", true},
+		{"/v1/users/*", "/v1/user", false},
+		{"*", "", true},
+		{"*a*b", "xxaxxb", true},
+		{"*a*b", "ab", true},
+		{"*a*b", "xxbxxa", false},
+		{"*a*b", "xxaxxbx", false},
+		{"/user.User/*", "/user.User/Get", true},
+		{"/user.User/*", "/order.Order/Get", false},
+		{"a*a", "a", false},
+	}
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	p, err := NewPolicy([]Role{
+		{Name: "reader", Rules: []Rule{{Resources: []string{"/v1/users/*"}, Actions: []string{"GET"}}}},
+		{Name: "admin", Rules: []Rule{{Resources: []string{"*"}, Actions: []string{"*"}}}},
+		{Name: "health", Rules: []Rule{{Resources: []string{"/healthz"}, Actions: []string{"GET"}}}},
+	}, map[string][]string{
+		"alice":  {"reader"},
+		"root":   {"admin"},
+		Wildcard: {"health"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		want bool
+	}{
+		{"role allows", Request{Subject: "alice", Resource: "/v1/users/1", Action: "GET"}, true},
+		{"action denied", Request{Subject: "alice", Resource: "/v1/users/1", Action: "DELETE"}, false},
+		{"resource denied", Request{Subject: "alice", Resource: "/v1/orders/1", Action: "GET"}, false},
+		{"admin", Request{Subject: "root", Resource: "/user.User/Delete", Action: ActionRPC}, true},
+		{"wildcard subject", Request{Subject: "bob", Resource: "/healthz", Action: "GET"}, true},
+		{"wildcard subject for anonymous", Request{Resource: "/healthz", Action: "GET"}, true},
+		{"unknown subject", Request{Subject: "bob", Resource: "/v1/users/1", Action: "GET"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Allowed(tt.req); got != tt.want {
+				t.Errorf("Allowed(%+v) = %v, want %v", tt.req, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsUnknownRole(t *testing.T) {
+	if _, err := NewPolicy(nil, map[string][]string{"alice": {"missing"}}); err == nil {
+		t.Error("binding an unknown role should fail")
+	}
+	if _, err := NewPolicy([]Role{{}}, nil); err == nil {
+		t.Error("a role without name should fail")
+	}
+}
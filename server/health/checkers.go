@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// Pinger is implemented by *sql.DB and most cache clients.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingChecker checks the db or cache by ping.
+func PingChecker(p Pinger) Checker {
+	return p.PingContext
+}
+
+// ConnChecker checks the connectivity of a grpc client connection,
+// an idle connection is asked to connect and regarded as healthy.
+func ConnChecker(conn *grpc.ClientConn) Checker {
+	return func(ctx context.Context) error {
+		switch state := conn.GetState(); state {
+		case connectivity.Ready, connectivity.Connecting:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+			return nil
+		default:
+			return errors.Errorf("grpc connection to %s is %s", conn.Target(), state)
+		}
+	}
+}
+
+// HTTPChecker checks the url returns 2xx.
+func HTTPChecker(client *http.Client, url string) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return errors.Errorf("%s returns %s", url, resp.Status)
+		}
+		return nil
+	}
+}
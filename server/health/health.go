@@ -0,0 +1,337 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+// Status is the health status.
+type Status string
+
+const (
+	// StatusUp means all checks pass.
+	StatusUp Status = "UP"
+	// StatusDegraded means some non-critical checks fail, the service is still ready.
+	StatusDegraded Status = "DEGRADED"
+	// StatusDown means some critical checks fail, or the service is shutting down.
+	StatusDown Status = "DOWN"
+)
+
+// Checker checks a dependency, e.g. a db ping, it returns nil if the dependency is healthy.
+type Checker func(ctx context.Context) error
+
+// CheckOption is check option.
+type CheckOption func(c *check)
+
+// WithTimeout sets the timeout of the check, 3s by default.
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = timeout
+	}
+}
+
+// WithCritical sets whether a failure makes the service not ready, true by default.
+func WithCritical(critical bool) CheckOption {
+	return func(c *check) {
+		c.critical = critical
+	}
+}
+
+// WithLiveness also runs the check for /live, a failure there makes the process restarted,
+// so only register the checks of the process itself, e.g. a deadlock detector.
+func WithLiveness() CheckOption {
+	return func(c *check) {
+		c.liveness = true
+	}
+}
+
+// WithServices binds the check to the grpc services, their status turns NOT_SERVING if a critical check fails.
+func WithServices(services ...string) CheckOption {
+	return func(c *check) {
+		c.services = services
+	}
+}
+
+type check struct {
+	name     string
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+	liveness bool
+	services []string
+}
+
+// Result is the result of a check.
+type Result struct {
+	Status   Status        `json:"status"`
+	Critical bool          `json:"critical"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Report is the result of all checks.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+	// Services is the status of the grpc services bound to checks
+	Services map[string]Status `json:"services,omitempty"`
+
+	// global is the status of the checks not bound to any service
+	global Status
+}
+
+// ServiceStatus returns the status of the grpc service, the services not bound to
+// checks follow the checks not bound to any service.
+func (r Report) ServiceStatus(service string) Status {
+	if st, ok := r.Services[service]; ok {
+		return st
+	}
+	return r.global
+}
+
+// Option is health option.
+type Option func(h *Health)
+
+// WithInterval sets the interval of the background checks which notify the watchers, 10s by default.
+func WithInterval(interval time.Duration) Option {
+	return func(h *Health) {
+		h.interval = interval
+	}
+}
+
+// Health runs the registered checks, the results drive the http probes and the grpc health server.
+type Health struct {
+	mu       sync.RWMutex
+	checks   map[string]*check
+	watchers []func(Report)
+
+	interval     time.Duration
+	shuttingDown int32
+
+	// done 停止后台检查, Resume 时重新开始
+	done    chan struct{}
+	running bool
+}
+
+// New creates a health.
+func New(opts ...Option) *Health {
+	h := &Health{
+		checks:   make(map[string]*check),
+		interval: 10 * time.Second,
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// Register registers a named check, the later one replaces the former one of the same name.
+func (h *Health) Register(name string, checker Checker, opts ...CheckOption) {
+	c := &check{name: name, checker: checker, timeout: 3 * time.Second, critical: true}
+	for _, o := range opts {
+		o(c)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = c
+}
+
+// Unregister removes the check.
+func (h *Health) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.checks, name)
+}
+
+// Watch calls fn with the report of every background check, the checks start on the first Watch.
+func (h *Health) Watch(fn func(Report)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.watchers = append(h.watchers, fn)
+	h.start()
+}
+
+// Shutdown marks the service as shutting down, the readiness is DOWN and the background checks
+// stop until Resume. The watchers are notified at once without running the checks,
+// a slow dependency does not delay the shutdown.
+func (h *Health) Shutdown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+	h.mu.Lock()
+	if h.running {
+		close(h.done)
+		h.running = false
+	}
+	r := Report{Status: StatusDown, global: StatusDown}
+	for _, c := range h.checks {
+		for _, s := range c.services {
+			if r.Services == nil {
+				r.Services = make(map[string]Status)
+			}
+			r.Services[s] = StatusDown
+		}
+	}
+	h.mu.Unlock()
+	h.notify(r)
+}
+
+// Resume marks the service as serving again, and restarts the background checks if watched.
+func (h *Health) Resume() {
+	atomic.StoreInt32(&h.shuttingDown, 0)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.watchers) > 0 {
+		h.start()
+	}
+}
+
+// start starts the background checks if not running, h.mu is held.
+func (h *Health) start() {
+	if h.running {
+		return
+	}
+	h.done = make(chan struct{})
+	h.running = true
+	go h.loop(h.done)
+}
+
+// Live runs the liveness checks.
+func (h *Health) Live(ctx context.Context) Report {
+	return h.evaluate(ctx, true)
+}
+
+// Ready runs all checks, the status is DOWN when shutting down.
+func (h *Health) Ready(ctx context.Context) Report {
+	r := h.evaluate(ctx, false)
+	if atomic.LoadInt32(&h.shuttingDown) == 1 {
+		r.Status, r.global = StatusDown, StatusDown
+		for s := range r.Services {
+			r.Services[s] = StatusDown
+		}
+	}
+	return r
+}
+
+func (h *Health) loop(done <-chan struct{}) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	last := Status("")
+	for {
+		r := h.Ready(context.Background())
+		if r.Status != last {
+			log.Infof("[health] status changes to %s", r.Status)
+			last = r.Status
+		}
+		h.notify(r)
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Health) notify(r Report) {
+	h.mu.RLock()
+	watchers := h.watchers
+	h.mu.RUnlock()
+	for _, fn := range watchers {
+		fn(r)
+	}
+}
+
+func (h *Health) evaluate(ctx context.Context, liveness bool) Report {
+	h.mu.RLock()
+	checks := make([]*check, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !liveness || c.liveness {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	r := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks)), global: StatusUp}
+	for i, c := range checks {
+		res := results[i]
+		r.Checks[c.name] = res
+		for _, s := range c.services {
+			if r.Services == nil {
+				r.Services = make(map[string]Status)
+			}
+			if _, ok := r.Services[s]; !ok {
+				r.Services[s] = StatusUp
+			}
+		}
+		if res.Status == StatusUp {
+			continue
+		}
+		if !c.critical {
+			if r.Status == StatusUp {
+				r.Status = StatusDegraded
+			}
+			continue
+		}
+		r.Status = StatusDown
+		if len(c.services) == 0 {
+			r.global = StatusDown
+		}
+		for _, s := range c.services {
+			r.Services[s] = StatusDown
+		}
+	}
+	// 全局的 critical 检查失败时, 所有服务都不可用
+	if r.global == StatusDown {
+		for s := range r.Services {
+			r.Services[s] = StatusDown
+		}
+	}
+	return r
+}
+
+// run runs the checker with timeout, the checker which ignores ctx is abandoned after timeout.
+func (c *check) run(ctx context.Context) (res Result) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		res.Duration = time.Since(start)
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- errors.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- c.checker(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return Result{Status: StatusDown, Critical: c.critical, Error: err.Error()}
+	}
+	return Result{Status: StatusUp, Critical: c.critical}
+}
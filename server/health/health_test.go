@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterRoutesSkipsExisting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/health", func(c *gin.Context) { c.String(http.StatusOK, "app") })
+
+	h := New()
+	h.Register("db", func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.1:3306: refused") })
+	h.RegisterRoutes(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Body.String() != "app" {
+		t.Errorf("/health = %q, want the route of the app", rec.Body.String())
+	}
+
+	for _, path := range []string{"/ready?verbose", "/live?verbose"} {
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var report Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if report.Checks != nil {
+			t.Errorf("%s shows the details without WithVerbose: %s", path, rec.Body.String())
+		}
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/ready = %d, want 503", rec.Code)
+	}
+}
+
+func TestRegisterRoutesVerbose(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := New()
+	h.Register("db", func(ctx context.Context) error { return errors.New("refused") })
+	h.RegisterRoutes(r, WithVerbose())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Checks["db"].Error != "refused" {
+		t.Errorf("/health = %s, want the error of db", rec.Body.String())
+	}
+}
+
+func TestResumeRestartsChecks(t *testing.T) {
+	h := New(WithInterval(10 * time.Millisecond))
+	reports := make(chan Report, 100)
+	h.Watch(func(r Report) {
+		select {
+		case reports <- r:
+		default:
+		}
+	})
+	waitStatus := func(want Status) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case r := <-reports:
+				if r.Status == want {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no report of %s", want)
+			}
+		}
+	}
+
+	waitStatus(StatusUp)
+	h.Shutdown()
+	waitStatus(StatusDown)
+	h.Resume()
+	waitStatus(StatusUp)
+}
+
+func TestShutdownSkipsChecks(t *testing.T) {
+	h := New(WithInterval(time.Hour))
+	release := make(chan struct{})
+	defer close(release)
+	h.Register("slow", func(ctx context.Context) error {
+		<-release
+		return nil
+	}, WithTimeout(time.Second), WithServices("user.v1.UserService"))
+
+	reports := make(chan Report, 1)
+	h.mu.Lock()
+	h.watchers = append(h.watchers, func(r Report) { reports <- r })
+	h.mu.Unlock()
+
+	start := time.Now()
+	h.Shutdown()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Shutdown took %s, want it not waiting for the checks", elapsed)
+	}
+	r := <-reports
+	if r.Status != StatusDown || r.ServiceStatus("user.v1.UserService") != StatusDown || r.ServiceStatus("") != StatusDown {
+		t.Errorf("report = %+v, want every service DOWN", r)
+	}
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/log"
+)
+
+// RouteOption is the option of the probe routes.
+type RouteOption func(o *routeOptions)
+
+type routeOptions struct {
+	verbose bool
+}
+
+// WithVerbose shows the check details on /health, and on /ready and /live with ?verbose,
+// the details contain the errors of the checks, keep them away from the public network.
+func WithVerbose() RouteOption {
+	return func(o *routeOptions) {
+		o.verbose = true
+	}
+}
+
+// RegisterRoutes registers the probes:
+//
+//	/health  the report of all checks
+//	/ready   the readiness, 503 if any critical check fails or shutting down
+//	/live    the liveness, 503 if any liveness check fails
+//
+// the details are omitted unless WithVerbose is set, the routes already registered on r are skipped.
+func (h *Health) RegisterRoutes(r gin.IRoutes, opts ...RouteOption) {
+	var o routeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	verbose := func(c *gin.Context) bool {
+		_, ok := c.GetQuery("verbose")
+		return o.verbose && ok
+	}
+	routes := []struct {
+		path    string
+		handler gin.HandlerFunc
+	}{
+		{"/health", func(c *gin.Context) {
+			writeReport(c, h.Ready(c.Request.Context()), o.verbose)
+		}},
+		{"/ready", func(c *gin.Context) {
+			writeReport(c, h.Ready(c.Request.Context()), verbose(c))
+		}},
+		{"/live", func(c *gin.Context) {
+			writeReport(c, h.Live(c.Request.Context()), verbose(c))
+		}},
+	}
+
+	// gin 重复注册路由会 panic, 跳过应用自己注册的路由
+	existing := map[string]bool{}
+	if engine, ok := r.(interface{ Routes() gin.RoutesInfo }); ok {
+		for _, ri := range engine.Routes() {
+			if ri.Method == http.MethodGet {
+				existing[ri.Path] = true
+			}
+		}
+	}
+	for _, rt := range routes {
+		if existing[rt.path] {
+			log.Infof("[health] %s is already registered, skip it", rt.path)
+			continue
+		}
+		r.GET(rt.path, rt.handler)
+	}
+}
+
+func writeReport(c *gin.Context, r Report, verbose bool) {
+	status := http.StatusOK
+	if r.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	if !verbose {
+		r.Checks, r.Services = nil, nil
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.JSON(status, r)
+}
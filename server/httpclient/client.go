@@ -0,0 +1,466 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chaos-ma/chaos/common/core"
+	"github.com/chaos-ma/chaos/core/baggage"
+	"github.com/chaos-ma/chaos/core/metric"
+	trace2 "github.com/chaos-ma/chaos/core/trace"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/registry"
+	"github.com/chaos-ma/chaos/server/rpcserver/selector"
+	"github.com/chaos-ma/chaos/server/rpcserver/selector/p2c"
+	"github.com/chaos-ma/chaos/server/tlsconfig"
+)
+
+const (
+	clientNamespace = "http_client"
+	discoveryScheme = "discovery"
+)
+
+type clientMetrics struct {
+	duration metric.HistogramVec
+	codes    metric.CounterVec
+}
+
+func newClientMetrics(reg metric.Registry) *clientMetrics {
+	return &clientMetrics{
+		duration: reg.NewHistogramVec(&metric.HistogramVecOpts{
+			Namespace: clientNamespace,
+			Subsystem: "requests",
+			Name:      "chaos_duration_ms",
+			Help:      "http client requests duration(ms).",
+			Labels:    []string{"method"},
+			Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		codes: reg.NewCounterVec(&metric.CounterVecOpts{
+			Namespace: clientNamespace,
+			Subsystem: "requests",
+			Name:      "chaos_code_total",
+			Help:      "http client requests code count.",
+			Labels:    []string{"method", "code"},
+		}),
+	}
+}
+
+type operationKey struct{}
+
+// NewOperationContext names the request in the metrics, e.g. "GET /users/:id", the http method is used if not set,
+// the raw path is never used to keep the label cardinality bounded.
+func NewOperationContext(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// FromOperationContext returns the operation in ctx if it exists.
+func FromOperationContext(ctx context.Context) (operation string, ok bool) {
+	operation, ok = ctx.Value(operationKey{}).(string)
+	return
+}
+
+type ClientOption func(o *clientOptions)
+
+type clientOptions struct {
+	endpoint      string
+	timeout       time.Duration
+	discovery     registry.Discovery
+	selector      selector.Builder
+	transport     http.RoundTripper
+	tlsOpts       *tlsconfig.Options
+	retries       int
+	retryBackoff  time.Duration
+	insecure      bool
+	enableTracing bool
+	enableMetrics bool
+	metrics       metric.Registry
+}
+
+// WithEndpoint sets the target, like discovery:///user-srv or http://127.0.0.1:8080.
+func WithEndpoint(endpoint string) ClientOption {
+	return func(o *clientOptions) {
+		o.endpoint = endpoint
+	}
+}
+
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+func WithDiscovery(d registry.Discovery) ClientOption {
+	return func(o *clientOptions) {
+		o.discovery = d
+	}
+}
+
+// WithSelector sets the selector builder which picks the discovered endpoints, defaults to p2c.
+func WithSelector(b selector.Builder) ClientOption {
+	return func(o *clientOptions) {
+		o.selector = b
+	}
+}
+
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// WithTLSConfig sets the tls config of the transport to call the secure endpoints.
+func WithTLSConfig(opts *tlsconfig.Options) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsOpts = opts
+	}
+}
+
+// WithRetry retries the request on network errors and 502/503/504 responses,
+// a retry prefers the nodes which have not been picked.
+func WithRetry(retries int, backoff time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.retries = retries
+		o.retryBackoff = backoff
+	}
+}
+
+// WithInsecure picks the http endpoints without isSecure=true.
+func WithInsecure(insecure bool) ClientOption {
+	return func(o *clientOptions) {
+		o.insecure = insecure
+	}
+}
+
+func WithEnableTracing(enable bool) ClientOption {
+	return func(o *clientOptions) {
+		o.enableTracing = enable
+	}
+}
+
+func WithEnableMetrics(enable bool) ClientOption {
+	return func(o *clientOptions) {
+		o.enableMetrics = enable
+	}
+}
+
+// WithMetricRegistry records the client metrics to reg instead of the default registry.
+func WithMetricRegistry(reg metric.Registry) ClientOption {
+	return func(o *clientOptions) {
+		o.metrics = reg
+	}
+}
+
+// Client is a http client which resolves the target through discovery.
+type Client struct {
+	opts    clientOptions
+	client  *http.Client
+	metrics *clientMetrics
+
+	// base is the target url when the target is not discovery
+	base     *url.URL
+	resolver *resolver
+	tls      *tlsconfig.Reloader
+}
+
+// NewClient creates a http client.
+func NewClient(ctx context.Context, opts ...ClientOption) (c *Client, err error) {
+	options := clientOptions{
+		timeout:       2000 * time.Millisecond,
+		transport:     http.DefaultTransport,
+		insecure:      true,
+		enableTracing: true,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	target, err := url.Parse(options.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var reloader *tlsconfig.Reloader
+	if options.tlsOpts != nil {
+		t, ok := options.transport.(*http.Transport)
+		if !ok {
+			return nil, errors.New("tls config requires *http.Transport")
+		}
+		reloader, err = tlsconfig.NewReloader(options.tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				reloader.Stop()
+			}
+		}()
+		t = t.Clone()
+		t.TLSClientConfig = reloader.ClientConfig()
+		dial := t.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		// 每个连接用当前的 CA 校验服务端
+		t.DialTLSContext = reloader.DialTLSContext(t.TLSClientConfig, dial)
+		options.transport = t
+	}
+	c = &Client{
+		opts:   options,
+		client: &http.Client{Transport: options.transport},
+		tls:    reloader,
+	}
+	if options.enableMetrics {
+		if options.metrics == nil {
+			options.metrics = metric.DefaultRegistry()
+		}
+		c.metrics = newClientMetrics(options.metrics)
+	}
+	if target.Scheme != discoveryScheme {
+		c.base = target
+		return c, nil
+	}
+
+	if options.discovery == nil {
+		return nil, errors.Errorf("discovery is required by the target: %s", options.endpoint)
+	}
+	builder := options.selector
+	if builder == nil {
+		builder = selector.GlobalSelector()
+	}
+	if builder == nil {
+		builder = p2c.NewBuilder()
+	}
+	c.resolver, err = newResolver(ctx, options.discovery, strings.TrimPrefix(target.Path, "/"),
+		builder.Build(), !options.insecure)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close stops watching the discovery and the tls files.
+func (c *Client) Close() error {
+	if c.tls != nil {
+		c.tls.Stop()
+	}
+	if c.resolver != nil {
+		return c.resolver.Close()
+	}
+	return nil
+}
+
+// Invoke sends the args as json and decodes the json response into reply,
+// a core.ErrResponse body is decoded into an errors.WithCode error.
+func (c *Client) Invoke(ctx context.Context, method, path string, args interface{}, reply interface{}) error {
+	var body []byte
+	if args != nil {
+		var err error
+		body, err = json.Marshal(args)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if args != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// Do sends the request to the picked endpoint, the scheme and host of req.URL are replaced,
+// req is not modified.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		resp, err := c.do(ctx, req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+	return c.do(ctx, req)
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	// 注入的 header 和重试时重置的 body 不能修改调用方的请求
+	req = req.Clone(ctx)
+	var span trace.Span
+	if c.opts.enableTracing {
+		ctx, span = otel.Tracer(trace2.TraceName).Start(ctx, "HTTP "+req.Method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.HTTPMethodKey.String(req.Method), semconv.HTTPTargetKey.String(req.URL.Path)))
+		defer span.End()
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		// 替换掉 propagator 注入的未过滤的 baggage
+		req.Header.Del("Baggage")
+	}
+	if req.Header.Get("Baggage") == "" {
+		baggage.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+	if requestID, ok := log.RequestIDFromContext(ctx); ok && req.Header.Get(log.RequestIDHeader) == "" {
+		req.Header.Set(log.RequestIDHeader, requestID)
+	}
+	if c.resolver != nil {
+		ctx = selector.NewAttemptsContext(ctx, &selector.Attempts{})
+	}
+
+	startTime := time.Now()
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		resp, err = c.attempt(ctx, req)
+		if attempt >= c.opts.retries || !retryable(resp, err) || !rewindable(req) {
+			break
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.opts.retryBackoff):
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	if c.metrics != nil {
+		operation := req.Method
+		if op, ok := FromOperationContext(ctx); ok && op != "" {
+			operation = op
+		}
+		c.metrics.duration.ObserveContext(ctx, float64(time.Since(startTime))/float64(time.Millisecond), operation)
+		c.metrics.codes.Inc(operation, strconv.Itoa(statusCode))
+	}
+	if span != nil {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(statusCode))
+			if statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, resp.Status)
+			}
+		}
+	}
+	return resp, err
+}
+
+func (c *Client) attempt(ctx context.Context, req *http.Request) (*http.Response, error) {
+	r := req.Clone(ctx)
+	if c.resolver == nil {
+		r.URL.Scheme = c.base.Scheme
+		r.URL.Host = c.base.Host
+		r.URL.Path = strings.TrimSuffix(c.base.Path, "/") + req.URL.Path
+		r.Host = c.base.Host
+		return c.client.Do(r)
+	}
+
+	node, done, err := c.resolver.selector.Select(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.URL.Scheme = node.Scheme()
+	r.URL.Host = node.Address()
+	r.Host = node.Address()
+	resp, err := c.client.Do(r)
+
+	di := selector.DoneInfo{Err: err}
+	if resp != nil {
+		di.BytesReceived = true
+		di.ReplyMD = resp.Header
+		if resp.StatusCode >= http.StatusInternalServerError {
+			di.Err = errors.Errorf("http status: %s", resp.Status)
+		}
+	}
+	done(ctx, di)
+	return resp, err
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// client.Do 的错误都是 *url.Error, 也是 net.Error, 先排除取消和超时
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, selector.ErrNoAvailable)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func decodeError(resp *http.Response) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var er core.ErrResponse
+	if err := json.Unmarshal(data, &er); err != nil || er.Code == 0 {
+		return errors.Errorf("http status: %s, body: %s", resp.Status, data)
+	}
+	return errors.WithCode(er.Code, "%s", er.Msg)
+}
+
+// cancelBody cancels the timeout context after the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,223 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/chaos-ma/chaos/core/baggage"
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/registry"
+	"github.com/chaos-ma/chaos/server/rpcserver/selector/random"
+)
+
+func TestMetricsLabelIsBounded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	reg := prom.NewRegistry()
+	c, err := NewClient(context.Background(), WithEndpoint(srv.URL), WithEnableMetrics(true),
+		WithEnableTracing(false), WithMetricRegistry(metric.NewRegistry(metric.WithPrometheus(reg))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, path := range []string{"/users/1", "/users/2"} {
+		if err := c.Invoke(context.Background(), http.MethodGet, path, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := NewOperationContext(context.Background(), "GET /users/:id")
+	if err := c.Invoke(ctx, http.MethodGet, "/users/3", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != "http_client_requests_chaos_code_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "method" {
+					got[l.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	want := map[string]float64{"GET": 2, "GET /users/:id": 1}
+	if len(got) != len(want) || got["GET"] != 2 || got["GET /users/:id"] != 1 {
+		t.Errorf("method labels = %v, want %v", got, want)
+	}
+	if n := testutil.CollectAndCount(reg, "http_client_requests_chaos_duration_ms"); n != 2 {
+		t.Errorf("duration series = %d, want 2", n)
+	}
+}
+
+// staticDiscovery returns the instances and never changes.
+type staticDiscovery []*registry.ServiceInstance
+
+func (d staticDiscovery) GetService(context.Context, string) ([]*registry.ServiceInstance, error) {
+	return d, nil
+}
+
+func (d staticDiscovery) Watch(ctx context.Context, _ string) (registry.Watcher, error) {
+	return &blockingWatcher{ctx: ctx}, nil
+}
+
+type blockingWatcher struct {
+	ctx context.Context
+}
+
+func (w *blockingWatcher) Next() ([]*registry.ServiceInstance, error) {
+	<-w.ctx.Done()
+	return nil, w.ctx.Err()
+}
+
+func (w *blockingWatcher) Stop() error {
+	return nil
+}
+
+func TestDiscoveryBalancing(t *testing.T) {
+	var hits [2]int32
+	d := staticDiscovery{}
+	for i := range hits {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits[i], 1)
+		}))
+		defer srv.Close()
+		d = append(d, &registry.ServiceInstance{ID: srv.URL, Name: "user-srv", Endpoints: []string{srv.URL}})
+	}
+
+	c, err := NewClient(context.Background(), WithEndpoint("discovery:///user-srv"), WithDiscovery(d),
+		WithSelector(random.NewBuilder()), WithEnableTracing(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 50; i++ {
+		if err := c.Invoke(context.Background(), http.MethodGet, "/users/1", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits[0] == 0 || hits[1] == 0 || hits[0]+hits[1] != 50 {
+		t.Errorf("hits = %v, want both instances picked", hits)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				bodies []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				n := len(bodies)
+				mu.Unlock()
+				if n < 3 {
+					w.WriteHeader(status)
+				}
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(context.Background(), WithEndpoint(srv.URL), WithRetry(3, time.Millisecond), WithEnableTracing(false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodPost, "/orders", bytes.NewReader([]byte(`{"id":1}`)))
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// 每次重试都重新发送 body
+			if len(bodies) != 3 || bodies[0] != `{"id":1}` || bodies[2] != `{"id":1}` {
+				t.Errorf("bodies = %q, want the body sent 3 times", bodies)
+			}
+		})
+	}
+}
+
+func TestNoRetryOnCanceledContext(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(context.Background(), WithEndpoint(srv.URL), WithRetry(3, 0),
+		WithTimeout(50*time.Millisecond), WithEnableTracing(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Invoke(context.Background(), http.MethodGet, "/slow", nil, nil); err == nil {
+		t.Fatal("the slow request should time out")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := c.Invoke(ctx, http.MethodGet, "/slow", nil, nil); err == nil {
+		t.Fatal("the canceled request should fail")
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("hits = %d, want no retry", n)
+	}
+}
+
+func TestDoKeepsCallerHeaders(t *testing.T) {
+	received := make(chan http.Header, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(context.Background(), WithEndpoint(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-Custom", "1")
+	for _, id := range []string{"req-1", "req-2"} {
+		ctx := baggage.WithTenant(log.NewRequestIDContext(context.Background(), id), "acme")
+		resp, err := c.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		got := <-received
+		// 复用的请求不带上一次的 request id
+		if got.Get(log.RequestIDHeader) != id || got.Get("Baggage") == "" || got.Get("X-Custom") != "1" {
+			t.Errorf("received headers = %v", got)
+		}
+	}
+	if len(req.Header) != 1 {
+		t.Errorf("caller headers = %v, want unmodified", req.Header)
+	}
+}
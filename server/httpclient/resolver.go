@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/registry"
+	"github.com/chaos-ma/chaos/server/rpcserver/resolver/discovery"
+	"github.com/chaos-ma/chaos/server/rpcserver/selector"
+)
+
+const endpointScheme = "http"
+
+// resolver watches the service instances and applies the http endpoints to the selector.
+type resolver struct {
+	w        registry.Watcher
+	selector selector.Selector
+	secure   bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newResolver(ctx context.Context, d registry.Discovery, name string,
+	s selector.Selector, secure bool) (*resolver, error) {
+	wctx, cancel := context.WithCancel(context.Background())
+	w, err := d.Watch(wctx, name)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	r := &resolver{
+		w:        w,
+		selector: s,
+		secure:   secure,
+		ctx:      wctx,
+		cancel:   cancel,
+	}
+	// fill the selector before the first request
+	if ins, err := d.GetService(ctx, name); err == nil {
+		r.update(ins)
+	} else {
+		log.Warnf("[httpclient] failed to get service %s: %v", name, err)
+	}
+	go r.watch()
+	return r, nil
+}
+
+func (r *resolver) watch() {
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		default:
+		}
+		ins, err := r.w.Next()
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			log.Errorf("[httpclient] Failed to watch discovery endpoint: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		r.update(ins)
+	}
+}
+
+func (r *resolver) update(ins []*registry.ServiceInstance) {
+	nodes := make([]selector.Node, 0, len(ins))
+	for _, in := range ins {
+		endpoint, err := discovery.ParseEndpoint(in.Endpoints, endpointScheme, r.secure)
+		if err != nil {
+			log.Errorf("[httpclient] Failed to parse discovery endpoint: %v", err)
+			continue
+		}
+		if endpoint == "" {
+			continue
+		}
+		scheme := endpointScheme
+		if r.secure {
+			scheme = "https"
+		}
+		nodes = append(nodes, selector.NewNode(scheme, endpoint, in))
+	}
+	if len(nodes) == 0 {
+		log.Warnf("[httpclient] Zero endpoint found,refused to write, instances: %v", ins)
+		return
+	}
+	r.selector.Apply(nodes)
+}
+
+func (r *resolver) Close() error {
+	r.cancel()
+	return r.w.Stop()
+}
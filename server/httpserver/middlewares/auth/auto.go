@@ -17,21 +17,36 @@ import (
 
 const authHeaderCount = 2
 
-// AutoStrategy defines authentication strategy which can automatically choose between Basic and Bearer
-// according `Authorization` header.
+// AutoStrategy defines authentication strategy which can automatically choose between Basic, Bearer
+// and HMAC-SHA256 according `Authorization` header.
 type AutoStrategy struct {
 	basic BasicStrategy
 	jwt   JWTStrategy
+	hmac  *HMACStrategy
 }
 
 var _ middlewares.AuthStrategy = &AutoStrategy{}
 
+// AutoOption is auto strategy option.
+type AutoOption func(a *AutoStrategy)
+
+// WithHMACStrategy dispatches the HMAC-SHA256 scheme to the hmac strategy.
+func WithHMACStrategy(h HMACStrategy) AutoOption {
+	return func(a *AutoStrategy) {
+		a.hmac = &h
+	}
+}
+
 // NewAutoStrategy create auto strategy with basic strategy and jwt strategy.
-func NewAutoStrategy(basic BasicStrategy, jwt JWTStrategy) AutoStrategy {
-	return AutoStrategy{
+func NewAutoStrategy(basic BasicStrategy, jwt JWTStrategy, opts ...AutoOption) AutoStrategy {
+	a := AutoStrategy{
 		basic: basic,
 		jwt:   jwt,
 	}
+	for _, o := range opts {
+		o(&a)
+	}
+	return a
 }
 
 // AuthFunc defines auto strategy as the gin authentication middleware.
@@ -57,6 +72,14 @@ func (a AutoStrategy) AuthFunc() gin.HandlerFunc {
 		case "Bearer":
 			operator.SetStrategy(a.jwt)
 			// a.JWT.MiddlewareFunc()(c)
+		case HMACScheme:
+			if a.hmac == nil {
+				core.WriteResponse(c, errors.WithCode(code.ErrSignatureInvalid, "unrecognized Authorization header."), nil)
+				c.Abort()
+
+				return
+			}
+			operator.SetStrategy(a.hmac)
 		default:
 			core.WriteResponse(c, errors.WithCode(code.ErrSignatureInvalid, "unrecognized Authorization header."), nil)
 			c.Abort()
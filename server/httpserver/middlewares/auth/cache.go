@@ -1,16 +1,18 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"time"
 
-	"github.com/dgrijalva/jwt-go/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/chaos-ma/chaos/code"
 	"github.com/chaos-ma/chaos/common/core"
 	"github.com/chaos-ma/chaos/errors"
 	"github.com/chaos-ma/chaos/server/httpserver/middlewares"
+	"github.com/chaos-ma/chaos/server/token"
 )
 
 // Defined errors.
@@ -30,14 +32,38 @@ type Secret struct {
 // CacheStrategy defines jwt bearer authentication strategy which called `cache strategy`.
 // Secrets are obtained through grpc api interface and cached in memory.
 type CacheStrategy struct {
-	get func(kid string) (Secret, error)
+	get     func(kid string) (Secret, error)
+	keys    token.KeySet
+	revoker *token.Revoker
 }
 
 var _ middlewares.AuthStrategy = &CacheStrategy{}
 
+// CacheOption is cache strategy option.
+type CacheOption func(cache *CacheStrategy)
+
+// WithKeySet verifies the RS256/ES256/EdDSA tokens with the public keys of keys,
+// e.g. a token.RemoteKeySet, the username is the sub claim of these tokens.
+func WithKeySet(keys token.KeySet) CacheOption {
+	return func(cache *CacheStrategy) {
+		cache.keys = keys
+	}
+}
+
+// WithRevoker rejects the revoked tokens, users and kids.
+func WithRevoker(r *token.Revoker) CacheOption {
+	return func(cache *CacheStrategy) {
+		cache.revoker = r
+	}
+}
+
 // NewCacheStrategy create cache strategy with function which can list and cache secrets.
-func NewCacheStrategy(get func(kid string) (Secret, error)) CacheStrategy {
-	return CacheStrategy{get}
+func NewCacheStrategy(get func(kid string) (Secret, error), opts ...CacheOption) CacheStrategy {
+	cache := CacheStrategy{get: get}
+	for _, o := range opts {
+		o(&cache)
+	}
+	return cache
 }
 
 // AuthFunc defines cache strategy as the gin authentication middleware.
@@ -55,50 +81,121 @@ func (cache CacheStrategy) AuthFunc() gin.HandlerFunc {
 		// Parse the header to get the token part.
 		fmt.Sscanf(header, "Bearer %s", &rawJWT)
 
-		// Use own validation logic, see below
-		var secret Secret
-
-		claims := &jwt.MapClaims{}
-		// Verify the token
-		parsedT, err := jwt.ParseWithClaims(rawJWT, claims, func(token *jwt.Token) (interface{}, error) {
-			// Validate the alg is HMAC signature
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			kid, ok := token.Header["kid"].(string)
-			if !ok {
-				return nil, ErrMissingKID
-			}
-
-			//我们的jwt的以前的认证方式是， 只要解密成功，就认为是合法的
-			//如果我有个恶意的用户，他可以伪造一个jwt，然后把kid设置成一个不存在的kid，这样就可以绕过认证，我们可以在token中放字符串
-			//我们想要拉黑一个用户
-			var err error
-			secret, err = cache.get(kid)
-			if err != nil {
-				return nil, ErrMissingSecret
-			}
-
-			return []byte(secret.Key), nil
-		}, jwt.WithAudience(AuthzAudience))
-		if err != nil || !parsedT.Valid {
-			core.WriteResponse(c, errors.WithCode(code.ErrSignatureInvalid, err.Error()), nil)
+		secret, err := cache.VerifyContext(c, rawJWT)
+		if err != nil {
+			core.WriteResponse(c, err, nil)
 			c.Abort()
 
 			return
 		}
 
-		if KeyExpired(secret.Expires) {
-			tm := time.Unix(secret.Expires, 0).Format("2006-01-02 15:04:05")
-			core.WriteResponse(c, errors.WithCode(code.ErrExpired, "expired at: %s", tm), nil)
-			c.Abort()
+		c.Set(middlewares.UsernameKey, secret.Username)
+		c.Next()
+	}
+}
 
-			return
+// Verify verifies the raw jwt with the secret of its kid, and returns the secret.
+func (cache CacheStrategy) Verify(rawJWT string) (Secret, error) {
+	return cache.VerifyContext(context.Background(), rawJWT)
+}
+
+// VerifyContext is Verify with the context passed to the revocation store.
+func (cache CacheStrategy) VerifyContext(ctx context.Context, rawJWT string) (Secret, error) {
+	// Use own validation logic, see below
+	var secret Secret
+
+	claims := jwt.MapClaims{}
+	// Verify the token
+	parsedT, err := jwt.ParseWithClaims(rawJWT, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, ErrMissingKID
 		}
 
-		c.Set(middlewares.UsernameKey, secret.Username)
-		c.Next()
+		// 非对称签名的 token 使用 key set 中的公钥验证
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return cache.verificationKey(token, kid)
+		}
+
+		//我们的jwt的以前的认证方式是， 只要解密成功，就认为是合法的
+		//如果我有个恶意的用户，他可以伪造一个jwt，然后把kid设置成一个不存在的kid，这样就可以绕过认证，我们可以在token中放字符串
+		//我们想要拉黑一个用户, 见 WithRevoker
+		var err error
+		secret, err = cache.get(kid)
+		if err != nil {
+			return nil, ErrMissingSecret
+		}
+
+		return []byte(secret.Key), nil
+	})
+	if err != nil {
+		return secret, errors.WithCode(code.ErrSignatureInvalid, err.Error())
+	}
+	if !parsedT.Valid || !claims.VerifyAudience(AuthzAudience, true) {
+		return secret, errors.WithCode(code.ErrSignatureInvalid, "token is invalid")
+	}
+	if _, ok := parsedT.Method.(*jwt.SigningMethodHMAC); !ok {
+		secret.Username, _ = claims["sub"].(string)
+		secret.ID, _ = parsedT.Header["kid"].(string)
+	}
+
+	if KeyExpired(secret.Expires) {
+		tm := time.Unix(secret.Expires, 0).Format("2006-01-02 15:04:05")
+		return secret, errors.WithCode(code.ErrExpired, "expired at: %s", tm)
+	}
+
+	if err := checkRevoked(ctx, cache.revoker, claims, secret.Username, secret.ID); err != nil {
+		return secret, err
+	}
+
+	return secret, nil
+}
+
+func (cache CacheStrategy) verificationKey(t *jwt.Token, kid string) (interface{}, error) {
+	if cache.keys == nil {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+	key, err := cache.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, ErrMissingSecret
+	}
+	if key.Algorithm != t.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	}
+
+	return key.Public, nil
+}
+
+// VerifyToken verifies the bearer token of grpc metadata and returns the username.
+func (cache CacheStrategy) VerifyToken(ctx context.Context, token string) (string, error) {
+	secret, err := cache.VerifyContext(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	return secret.Username, nil
+}
+
+// NewCacheTokenSource returns a token source which signs tokens with secret,
+// the tokens are verified by the cache strategy of the peers.
+func NewCacheTokenSource(secret Secret, ttl time.Duration) func(ctx context.Context) (string, time.Time, error) {
+	return func(ctx context.Context) (string, time.Time, error) {
+		now := time.Now()
+		expiresAt := now.Add(ttl)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{AuthzAudience},
+			Subject:   secret.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		})
+		token.Header["kid"] = secret.ID
+		signed, err := token.SignedString([]byte(secret.Key))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		return signed, expiresAt, nil
 	}
 }
 
@@ -1,2 +1,2 @@
-// Package auth defines authentication strategy like Basic, Bearer.
+// Package auth defines authentication strategy like Basic, Bearer and HMAC signed requests.
 package auth
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/common/core"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/server/httpserver/middlewares"
+)
+
+// HMACScheme is the Authorization scheme of signed requests:
+//
+//	Authorization: HMAC-SHA256 <kid>:<base64 signature>
+//	X-Chaos-Timestamp: <unix seconds>
+//	X-Chaos-Nonce: <random string>
+//
+// the signature is HMAC-SHA256 of "method\npath?query\ntimestamp\nnonce\nhex(sha256(body))" with the secret of kid.
+const (
+	HMACScheme      = "HMAC-SHA256"
+	TimestampHeader = "X-Chaos-Timestamp"
+	NonceHeader     = "X-Chaos-Nonce"
+)
+
+// NonceStore remembers the nonces in the replay window.
+type NonceStore interface {
+	// Add returns false if the nonce has been added and not expired.
+	Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+type memoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	sweep  time.Time
+}
+
+// NewMemoryNonceStore creates an in-memory nonce store, use a shared store
+// if the requests are balanced between instances.
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *memoryNonceStore) Add(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.After(s.sweep) {
+		for n, expires := range s.nonces {
+			if now.After(expires) {
+				delete(s.nonces, n)
+			}
+		}
+		s.sweep = now.Add(ttl)
+	}
+	if expires, ok := s.nonces[nonce]; ok && now.Before(expires) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// HMACOption is hmac strategy option.
+type HMACOption func(h *HMACStrategy)
+
+// WithReplayWindow sets how far the timestamp may drift from now, 5 minutes by default.
+func WithReplayWindow(window time.Duration) HMACOption {
+	return func(h *HMACStrategy) {
+		h.window = window
+	}
+}
+
+// WithNonceStore replaces the in-memory nonce store.
+func WithNonceStore(store NonceStore) HMACOption {
+	return func(h *HMACStrategy) {
+		h.nonces = store
+	}
+}
+
+// WithMaxBodySize limits the body size to hash, 10MB by default.
+func WithMaxBodySize(size int64) HMACOption {
+	return func(h *HMACStrategy) {
+		h.maxBodySize = size
+	}
+}
+
+// HMACStrategy defines the API key authentication strategy, the requests are signed by the secret of the key.
+type HMACStrategy struct {
+	get         func(kid string) (Secret, error)
+	window      time.Duration
+	nonces      NonceStore
+	maxBodySize int64
+}
+
+var _ middlewares.AuthStrategy = &HMACStrategy{}
+
+// NewHMACStrategy create hmac strategy with function which can list and cache secrets.
+func NewHMACStrategy(get func(kid string) (Secret, error), opts ...HMACOption) HMACStrategy {
+	h := HMACStrategy{
+		get:         get,
+		window:      5 * time.Minute,
+		maxBodySize: 10 << 20,
+	}
+	for _, o := range opts {
+		o(&h)
+	}
+	if h.nonces == nil {
+		h.nonces = NewMemoryNonceStore()
+	}
+	return h
+}
+
+// AuthFunc defines hmac strategy as the gin authentication middleware.
+func (h HMACStrategy) AuthFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret, err := h.Verify(c.Request)
+		if err != nil {
+			core.WriteResponse(c, err, nil)
+			c.Abort()
+
+			return
+		}
+
+		c.Set(middlewares.UsernameKey, secret.Username)
+		c.Next()
+	}
+}
+
+// Verify verifies the signature of the request, the body is restored for the handlers.
+func (h HMACStrategy) Verify(req *http.Request) (Secret, error) {
+	var secret Secret
+
+	auth := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(auth) != 2 || auth[0] != HMACScheme {
+		return secret, errors.WithCode(code.ErrInvalidAuthHeader, "Authorization header format is wrong.")
+	}
+	pair := strings.SplitN(auth[1], ":", 2)
+	if len(pair) != 2 {
+		return secret, errors.WithCode(code.ErrInvalidAuthHeader, "Authorization header format is wrong.")
+	}
+	kid := pair[0]
+	signature, err := base64.StdEncoding.DecodeString(pair[1])
+	if err != nil {
+		return secret, errors.WithCode(code.ErrInvalidAuthHeader, "signature is not base64 encoded.")
+	}
+
+	timestamp := req.Header.Get(TimestampHeader)
+	nonce := req.Header.Get(NonceHeader)
+	if timestamp == "" || nonce == "" {
+		return secret, errors.WithCode(code.ErrMissingHeader, "%s and %s headers cannot be empty.", TimestampHeader, NonceHeader)
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return secret, errors.WithCode(code.ErrInvalidAuthHeader, "%s header format is wrong.", TimestampHeader)
+	}
+	if drift := time.Since(time.Unix(sec, 0)); drift > h.window || drift < -h.window {
+		return secret, errors.WithCode(code.ErrExpired, "request timestamp is out of the replay window.")
+	}
+
+	secret, err = h.get(kid)
+	if err != nil {
+		return secret, errors.WithCode(code.ErrSignatureInvalid, ErrMissingSecret.Error())
+	}
+	if KeyExpired(secret.Expires) {
+		tm := time.Unix(secret.Expires, 0).Format("2006-01-02 15:04:05")
+		return secret, errors.WithCode(code.ErrExpired, "expired at: %s", tm)
+	}
+
+	bodyHash, err := h.hashBody(req)
+	if err != nil {
+		return secret, errors.WithCode(code.ErrBind, err.Error())
+	}
+	expected := sign(secret.Key, req.Method, req.URL.RequestURI(), timestamp, nonce, bodyHash)
+	if !hmac.Equal(signature, expected) {
+		return secret, errors.WithCode(code.ErrSignatureInvalid, "signature is invalid.")
+	}
+
+	// 签名验证通过后再记录 nonce, 避免伪造的请求占用 nonce
+	ok, err := h.nonces.Add(req.Context(), kid+":"+nonce, 2*h.window)
+	if err != nil {
+		return secret, errors.WithCode(code.ErrUnknown, err.Error())
+	}
+	if !ok {
+		return secret, errors.WithCode(code.ErrSignatureInvalid, "nonce has been used.")
+	}
+
+	return secret, nil
+}
+
+func (h HMACStrategy) hashBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return hashBytes(nil), nil
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, h.maxBodySize+1))
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	if int64(len(body)) > h.maxBodySize {
+		return "", errors.New("request body is too large to verify")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return hashBytes(body), nil
+}
+
+func hashBytes(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func sign(key, method, uri, timestamp, nonce, bodyHash string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.Join([]string{method, uri, timestamp, nonce, bodyHash}, "\n")))
+	return mac.Sum(nil)
+}
+
+// SignRequest signs the request with the secret, it is used by the callers of HMACStrategy.
+func SignRequest(req *http.Request, secret Secret) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.NewString()
+	signature := sign(secret.Key, req.Method, req.URL.RequestURI(), timestamp, nonce, hashBytes(body))
+
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set("Authorization", HMACScheme+" "+secret.ID+":"+base64.StdEncoding.EncodeToString(signature))
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/common/core"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/server/httpserver/middlewares"
+)
+
+var testSecret = Secret{Username: "alice", ID: "kid-1", Key: "s3cret"}
+
+func getTestSecret(kid string) (Secret, error) {
+	if kid != testSecret.ID {
+		return Secret{}, ErrMissingSecret
+	}
+	return testSecret, nil
+}
+
+func newSignedRequest(t *testing.T, method, target, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if err := SignRequest(req, testSecret); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// resign signs the request again with the timestamp.
+func resign(t *testing.T, req *http.Request, secret Secret, timestamp time.Time) {
+	t.Helper()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	nonce := req.Header.Get(NonceHeader)
+	signature := sign(secret.Key, req.Method, req.URL.RequestURI(), ts, nonce, hashBytes(body))
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set("Authorization", HMACScheme+" "+secret.ID+":"+base64.StdEncoding.EncodeToString(signature))
+}
+
+func TestHMACVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(req *http.Request)
+		code   int
+	}{
+		{"valid", func(req *http.Request) {}, 0},
+		{"tampered body", func(req *http.Request) {
+			req.Body = io.NopCloser(strings.NewReader(`{"amount":1000}`))
+		}, code.ErrSignatureInvalid},
+		{"tampered path", func(req *http.Request) { req.URL.Path = "/v1/admin" }, code.ErrSignatureInvalid},
+		{"tampered query", func(req *http.Request) { req.URL.RawQuery = "dry=false" }, code.ErrSignatureInvalid},
+		{"tampered method", func(req *http.Request) { req.Method = http.MethodDelete }, code.ErrSignatureInvalid},
+		{"old timestamp", func(req *http.Request) {
+			resign(t, req, testSecret, time.Now().Add(-10*time.Minute))
+		}, code.ErrExpired},
+		{"future timestamp", func(req *http.Request) {
+			resign(t, req, testSecret, time.Now().Add(10*time.Minute))
+		}, code.ErrExpired},
+		{"unknown kid", func(req *http.Request) {
+			resign(t, req, Secret{ID: "kid-2", Key: "s3cret"}, time.Now())
+		}, code.ErrSignatureInvalid},
+		{"wrong key", func(req *http.Request) {
+			resign(t, req, Secret{ID: testSecret.ID, Key: "guess"}, time.Now())
+		}, code.ErrSignatureInvalid},
+		{"missing nonce", func(req *http.Request) { req.Header.Del(NonceHeader) }, code.ErrMissingHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHMACStrategy(getTestSecret)
+			req := newSignedRequest(t, http.MethodPost, "/v1/transfers?dry=true", `{"amount":1}`)
+			tt.tamper(req)
+			secret, err := h.Verify(req)
+			if tt.code == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if secret.Username != "alice" {
+					t.Errorf("username = %q, want alice", secret.Username)
+				}
+				return
+			}
+			if c := errors.ParseCoder(err).Code(); c != tt.code {
+				t.Errorf("code = %d, want %d, err = %v", c, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestHMACReplayedNonce(t *testing.T) {
+	h := NewHMACStrategy(getTestSecret)
+	req := newSignedRequest(t, http.MethodGet, "/v1/users", "")
+	if _, err := h.Verify(req); err != nil {
+		t.Fatal(err)
+	}
+	replay := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	replay.Header = req.Header.Clone()
+	_, err := h.Verify(replay)
+	if c := errors.ParseCoder(err).Code(); c != code.ErrSignatureInvalid {
+		t.Errorf("replay: code = %d, want code.ErrSignatureInvalid, err = %v", c, err)
+	}
+}
+
+func TestHMACAuthFuncRestoresBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(NewHMACStrategy(getTestSecret).AuthFunc())
+	var body, username string
+	r.POST("/v1/transfers", func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		body, username = string(data), c.GetString(middlewares.UsernameKey)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, newSignedRequest(t, http.MethodPost, "/v1/transfers", `{"amount":1}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if body != `{"amount":1}` || username != "alice" {
+		t.Errorf("handler got body %q, username %q", body, username)
+	}
+}
+
+func TestAutoStrategyDispatchesHMAC(t *testing.T) {
+	basic := NewBasicStrategy(func(username, password string) bool { return false })
+	handle := func(a AutoStrategy, req *http.Request) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(a.AuthFunc())
+		r.GET("/v1/users", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString(middlewares.UsernameKey))
+		})
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := handle(NewAutoStrategy(basic, JWTStrategy{}, WithHMACStrategy(NewHMACStrategy(getTestSecret))),
+		newSignedRequest(t, http.MethodGet, "/v1/users", ""))
+	if rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Errorf("with hmac: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 没有配置 hmac 时拒绝该 scheme
+	rec = handle(NewAutoStrategy(basic, JWTStrategy{}), newSignedRequest(t, http.MethodGet, "/v1/users", ""))
+	var resp core.ErrResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != code.ErrSignatureInvalid {
+		t.Errorf("without hmac: code = %d, want code.ErrSignatureInvalid", resp.Code)
+	}
+}
@@ -1,10 +1,18 @@
 package auth
 
 import (
+	"context"
+	"time"
+
 	ginjwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/common/core"
+	"github.com/chaos-ma/chaos/errors"
 	"github.com/chaos-ma/chaos/server/httpserver/middlewares"
+	"github.com/chaos-ma/chaos/server/token"
 )
 
 // AuthzAudience defines the value of jwt audience field.
@@ -13,16 +21,95 @@ const AuthzAudience = "chaos-ma/chaos"
 // JWTStrategy defines jwt bearer authentication strategy.
 type JWTStrategy struct {
 	ginjwt.GinJWTMiddleware
+	revoker *token.Revoker
 }
 
 var _ middlewares.AuthStrategy = &JWTStrategy{}
 
+// JWTOption is jwt strategy option.
+type JWTOption func(j *JWTStrategy)
+
+// WithJWTRevoker rejects the tokens of the revoked users, gin-jwt tokens carry no jti,
+// so they can only be revoked by user.
+func WithJWTRevoker(r *token.Revoker) JWTOption {
+	return func(j *JWTStrategy) {
+		j.revoker = r
+	}
+}
+
 // NewJWTStrategy create jwt bearer strategy with GinJWTMiddleware.
-func NewJWTStrategy(gjwt ginjwt.GinJWTMiddleware) JWTStrategy {
-	return JWTStrategy{gjwt}
+func NewJWTStrategy(gjwt ginjwt.GinJWTMiddleware, opts ...JWTOption) JWTStrategy {
+	j := JWTStrategy{GinJWTMiddleware: gjwt}
+	for _, o := range opts {
+		o(&j)
+	}
+	return j
 }
 
 // AuthFunc defines jwt bearer strategy as the gin authentication middleware.
 func (j JWTStrategy) AuthFunc() gin.HandlerFunc {
-	return j.MiddlewareFunc()
+	mw := j.MiddlewareFunc()
+	if j.revoker == nil {
+		return mw
+	}
+
+	return func(c *gin.Context) {
+		// 解析失败交给 gin-jwt 处理
+		if claims, err := j.GetClaimsFromJWT(c); err == nil {
+			identity, _ := claims[j.IdentityKey].(string)
+			if err := checkRevoked(c, j.revoker, jwt.MapClaims(claims), identity, ""); err != nil {
+				core.WriteResponse(c, err, nil)
+				c.Abort()
+
+				return
+			}
+		}
+		mw(c)
+	}
+}
+
+// VerifyToken verifies the bearer token of grpc metadata and returns the identity.
+func (j JWTStrategy) VerifyToken(ctx context.Context, token string) (string, error) {
+	parsed, err := j.ParseTokenString(token)
+	if err != nil {
+		return "", errors.WithCode(code.ErrSignatureInvalid, err.Error())
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return "", errors.WithCode(code.ErrSignatureInvalid, "token is invalid")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return "", errors.WithCode(code.ErrSignatureInvalid, ginjwt.ErrMissingExpField.Error())
+	}
+	identity, _ := claims[j.IdentityKey].(string)
+	if err := checkRevoked(ctx, j.revoker, claims, identity, ""); err != nil {
+		return "", err
+	}
+
+	return identity, nil
+}
+
+// checkRevoked checks the revocation of the token by its jti, user and kid.
+func checkRevoked(ctx context.Context, r *token.Revoker, claims jwt.MapClaims, user, kid string) error {
+	if r == nil {
+		return nil
+	}
+	t := token.Token{User: user, KeyID: kid}
+	t.ID, _ = claims["jti"].(string)
+	// gin-jwt 的 token 没有 iat, 使用 orig_iat
+	for _, name := range []string{"iat", "orig_iat"} {
+		if iat, ok := claims[name].(float64); ok {
+			t.IssuedAt = time.Unix(int64(iat), 0)
+			break
+		}
+	}
+
+	if err := r.Check(ctx, t); err != nil {
+		if errors.Is(err, token.ErrTokenRevoked) {
+			return errors.WithCode(code.ErrTokenRevoked, err.Error())
+		}
+		return errors.WithCode(code.ErrUnknown, err.Error())
+	}
+
+	return nil
 }
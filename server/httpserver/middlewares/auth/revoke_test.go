@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ginjwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/server/token"
+)
+
+func TestJWTStrategyRevoker(t *testing.T) {
+	ctx := context.Background()
+	// token 在两秒前签发, 早于吊销时间
+	issuedAt := time.Now().Add(-2 * time.Second)
+	mw, err := ginjwt.New(&ginjwt.GinJWTMiddleware{
+		Realm:       "test",
+		Key:         []byte("secret"),
+		Timeout:     time.Hour,
+		IdentityKey: "username",
+		TimeFunc:    func() time.Time { return issuedAt },
+		PayloadFunc: func(data interface{}) ginjwt.MapClaims {
+			return ginjwt.MapClaims{"username": data}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _, err := mw.TokenGenerator("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := token.NewRevoker(token.NewMemoryRevocationStore())
+	j := NewJWTStrategy(*mw, WithJWTRevoker(r))
+	if username, err := j.VerifyToken(ctx, tok); err != nil || username != "alice" {
+		t.Fatalf("VerifyToken = %q, %v", username, err)
+	}
+	if err := r.RevokeUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = j.VerifyToken(ctx, tok)
+	if c := errors.ParseCoder(err).Code(); c != code.ErrTokenRevoked {
+		t.Errorf("code = %d, want code.ErrTokenRevoked, err = %v", c, err)
+	}
+}
+
+func TestCacheStrategyRevoker(t *testing.T) {
+	ctx := context.Background()
+	sign := func(jti string) string {
+		t.Helper()
+		now := time.Now()
+		tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			ID:        jti,
+			Audience:  jwt.ClaimStrings{AuthzAudience},
+			Subject:   testSecret.Username,
+			IssuedAt:  jwt.NewNumericDate(now.Add(-2 * time.Second)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		})
+		tok.Header["kid"] = testSecret.ID
+		signed, err := tok.SignedString([]byte(testSecret.Key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		revoke func(r *token.Revoker) error
+	}{
+		{"jti", func(r *token.Revoker) error { return r.RevokeToken(ctx, "jti-1", time.Time{}) }},
+		{"user", func(r *token.Revoker) error { return r.RevokeUser(ctx, testSecret.Username) }},
+		{"kid", func(r *token.Revoker) error { return r.RevokeKey(ctx, testSecret.ID) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := token.NewRevoker(token.NewMemoryRevocationStore())
+			cache := NewCacheStrategy(getTestSecret, WithRevoker(r))
+			tok := sign("jti-1")
+			if username, err := cache.VerifyToken(ctx, tok); err != nil || username != "alice" {
+				t.Fatalf("VerifyToken = %q, %v", username, err)
+			}
+			if err := tt.revoke(r); err != nil {
+				t.Fatal(err)
+			}
+			_, err := cache.VerifyToken(ctx, tok)
+			if c := errors.ParseCoder(err).Code(); c != code.ErrTokenRevoked {
+				t.Errorf("code = %d, want code.ErrTokenRevoked, err = %v", c, err)
+			}
+		})
+	}
+}
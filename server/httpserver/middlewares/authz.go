@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/common/core"
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/server/authz"
+)
+
+// Authorize authorizes the authenticated user on the route template and the http method,
+// it must be installed after the authentication middleware which sets UsernameKey.
+func Authorize(a *authz.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource := c.FullPath()
+		if resource == "" {
+			resource = c.Request.URL.Path
+		}
+		req := authz.Request{
+			Subject:  c.GetString(UsernameKey),
+			Resource: resource,
+			Action:   c.Request.Method,
+		}
+
+		allowed, err := a.Authorize(c, req)
+		if err != nil {
+			core.WriteResponse(c, errors.WrapC(err, code.ErrPermissionDenied, "authorization failed"), nil)
+			c.Abort()
+
+			return
+		}
+		if !allowed {
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied,
+				"%s is not allowed to %s %s", req.Subject, req.Action, req.Resource), nil)
+			c.Abort()
+
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/code"
+	"github.com/chaos-ma/chaos/common/core"
+	"github.com/chaos-ma/chaos/server/authz"
+)
+
+func TestAuthorize(t *testing.T) {
+	p, err := authz.NewPolicy([]authz.Role{
+		{Name: "reader", Rules: []authz.Rule{{Resources: []string{"/v1/users/*"}, Actions: []string{"GET"}}}},
+	}, map[string][]string{"alice": {"reader"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := authz.NewAuthorizer(authz.WithPolicy(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(UsernameKey, c.GetHeader("X-User"))
+	}, Authorize(a))
+	// 策略匹配路由模板而不是实际路径
+	r.GET("/v1/users/*id", func(c *gin.Context) {})
+	r.DELETE("/v1/users/*id", func(c *gin.Context) {})
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		status int
+	}{
+		{"allowed", "alice", http.MethodGet, http.StatusOK},
+		{"action denied", "alice", http.MethodDelete, http.StatusForbidden},
+		{"unknown user", "bob", http.MethodGet, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/users/1", nil)
+			req.Header.Set("X-User", tt.user)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				return
+			}
+			var resp core.ErrResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != code.ErrPermissionDenied {
+				t.Errorf("code = %d, want code.ErrPermissionDenied", resp.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/chaos-ma/chaos/core/baggage"
+)
+
+// Baggage extracts the allowed members of the baggage header into the request context,
+// the members of other keys are dropped, it runs after the tracing middleware.
+func Baggage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := baggage.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/chaos-ma/chaos/core/baggage"
+)
+
+func TestBaggage(t *testing.T) {
+	old := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	defer otel.SetTextMapPropagator(old)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// tracing 中间件提取了未过滤的 baggage
+	r.Use(TracingHandler("test"), Baggage())
+	var tenant, user, session string
+	var canary bool
+	outgoing := http.Header{}
+	r.GET("/users", func(c *gin.Context) {
+		ctx := c.Request.Context()
+		tenant, user, canary, session = baggage.Tenant(ctx), baggage.User(ctx), baggage.Canary(ctx), baggage.Get(ctx, "session")
+		baggage.Inject(ctx, propagation.HeaderCarrier(outgoing))
+	})
+
+	// 上游用 baggage 包注入, 值需要转义
+	ctx := baggage.WithCanary(baggage.WithUser(baggage.WithTenant(context.Background(), "acme, inc"), "alice"), true)
+	ctx, err := baggage.With(ctx, "session", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if tenant != "acme, inc" || user != "alice" || !canary {
+		t.Errorf("tenant = %q, user = %q, canary = %v", tenant, user, canary)
+	}
+	if session != "" {
+		t.Errorf("session = %q, want dropped", session)
+	}
+	next := baggage.Extract(context.Background(), propagation.HeaderCarrier(outgoing))
+	if baggage.Tenant(next) != "acme, inc" || baggage.User(next) != "alice" || !baggage.Canary(next) {
+		t.Errorf("outgoing baggage = %q", outgoing.Get("baggage"))
+	}
+}
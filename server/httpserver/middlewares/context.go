@@ -4,18 +4,31 @@ package middlewares
 * created by mengqi on 2023/11/21
  */
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/chaos-ma/chaos/log"
+)
 
 const (
-	UsernameKey = "username"
+	// UsernameKey is set by the auth strategies, log.UsernameFromContext reads it from *gin.Context
+	UsernameKey = log.KeyUsername
 	KeyUserID   = "userid"
 	UserIP      = "ip"
 )
 
-// Context 为每个请求添加上下文
+// Context 为每个请求添加上下文, 读取或生成 X-Request-ID, 并在响应中回显
 func Context() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		//TODO 自定义扩展
+		requestID := c.GetHeader(log.RequestIDHeader)
+		if !log.ValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(log.KeyRequestID, requestID)
+		c.Request = c.Request.WithContext(log.NewRequestIDContext(c.Request.Context(), requestID))
+		c.Header(log.RequestIDHeader, requestID)
 		c.Next()
 	}
 }
@@ -6,22 +6,175 @@ package middlewares
 
 import (
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/errors"
 )
 
+// CorsOptions is the cors configuration.
+type CorsOptions struct {
+	// AllowOrigins are exact origins or wildcard subdomains, e.g. "https://example.com", "https://*.example.com",
+	// "*" allows any origin and can not be used with AllowCredentials
+	AllowOrigins []string `json:"allow-origins" mapstructure:"allow-origins"`
+	// AllowOriginPatterns are regular expressions matching the whole origin, the patterns matching
+	// any origin like ".*" can not be used with AllowCredentials either
+	AllowOriginPatterns []string      `json:"allow-origin-patterns" mapstructure:"allow-origin-patterns"`
+	AllowMethods        []string      `json:"allow-methods"         mapstructure:"allow-methods"`
+	AllowHeaders        []string      `json:"allow-headers"         mapstructure:"allow-headers"`
+	ExposeHeaders       []string      `json:"expose-headers"        mapstructure:"expose-headers"`
+	AllowCredentials    bool          `json:"allow-credentials"     mapstructure:"allow-credentials"`
+	MaxAge              time.Duration `json:"max-age"               mapstructure:"max-age"`
+}
+
+// NewCorsOptions returns the default cors options, any origin without credentials.
+func NewCorsOptions() *CorsOptions {
+	return &CorsOptions{
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders:  []string{"Content-Type", "AccessToken", "X-CSRF-Token", "Authorization", "Token", "X-Token"},
+		ExposeHeaders: []string{"Content-Length", "Content-Type"},
+		MaxAge:        12 * time.Hour,
+	}
+}
+
+// Cors allows any origin without credentials, use CorsWithOptions to restrict the origins.
 func Cors() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		method := c.Request.Method
+	return CorsWithOptions(NewCorsOptions())
+}
+
+type cors struct {
+	anyOrigin     bool
+	origins       map[string]struct{}
+	wildcards     [][2]string
+	patterns      []*regexp.Regexp
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
 
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token, x-token")
-		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, DELETE, PATCH, PUT")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
-		c.Header("Access-Control-Allow-Credentials", "true")
+// CorsWithOptions creates the cors middleware, it panics if the options are invalid, see NewCors.
+func CorsWithOptions(opts *CorsOptions) gin.HandlerFunc {
+	h, err := NewCors(opts)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
 
-		if method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+// NewCors creates the cors middleware, it returns an error if a pattern is not a valid regular expression,
+// or any origin is allowed with credentials.
+func NewCors(opts *CorsOptions) (gin.HandlerFunc, error) {
+	c := &cors{
+		origins:       make(map[string]struct{}),
+		allowMethods:  strings.Join(opts.AllowMethods, ", "),
+		allowHeaders:  strings.Join(opts.AllowHeaders, ", "),
+		exposeHeaders: strings.Join(opts.ExposeHeaders, ", "),
+		credentials:   opts.AllowCredentials,
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.FormatInt(int64(opts.MaxAge/time.Second), 10)
+	}
+	for _, o := range opts.AllowOrigins {
+		o = strings.ToLower(o)
+		switch {
+		case o == "*":
+			c.anyOrigin = true
+		case strings.Contains(o, "*"):
+			parts := strings.SplitN(o, "*", 2)
+			c.wildcards = append(c.wildcards, [2]string{parts[0], parts[1]})
+		default:
+			c.origins[o] = struct{}{}
 		}
 	}
+	for _, p := range opts.AllowOriginPatterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "cors: invalid origin pattern %q", p)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	// 任意 origin 加上 credentials 等于信任所有站点的带 cookie 请求, 回显 origin 的通配也一样
+	if c.credentials && (c.anyOrigin || c.allowed(probeOrigin)) {
+		return nil, errors.New("cors: any origin can not be allowed with credentials, list the origins instead")
+	}
+
+	return c.handle, nil
+}
+
+// probeOrigin is an origin no site owns, the options allowing it allow any origin.
+const probeOrigin = "https://cors-probe.invalid"
+
+func (c *cors) allowed(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := c.origins[origin]; ok {
+		return true
+	}
+	for _, w := range c.wildcards {
+		// 通配符至少匹配一级子域名
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	for _, p := range c.patterns {
+		if p.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) handle(ctx *gin.Context) {
+	origin := ctx.GetHeader("Origin")
+	preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+	h := ctx.Writer.Header()
+	// 响应随 Origin 变化, 没有 Origin 的响应也不能被缓存给跨域请求
+	if !c.anyOrigin {
+		h.Add("Vary", "Origin")
+	}
+	if origin == "" {
+		return
+	}
+	if !c.allowed(origin) {
+		if preflight {
+			ctx.AbortWithStatus(http.StatusForbidden)
+		}
+		return
+	}
+
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if c.exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+		}
+		return
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if c.allowHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	ctx.AbortWithStatus(http.StatusNoContent)
 }
@@ -0,0 +1,161 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCorsEngine(t *testing.T, opts *CorsOptions) *gin.Engine {
+	t.Helper()
+	h, err := NewCors(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(h)
+	r.GET("/users", func(c *gin.Context) {})
+	return r
+}
+
+func TestCorsOrigins(t *testing.T) {
+	opts := NewCorsOptions()
+	opts.AllowOrigins = []string{"https://example.com", "https://*.example.org"}
+	opts.AllowOriginPatterns = []string{`https://review-\d+\.example\.net`}
+	opts.AllowCredentials = true
+	r := newCorsEngine(t, opts)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://example.com", true},
+		{"https://EXAMPLE.com", true},
+		{"http://example.com", false},
+		{"https://example.com.evil.com", false},
+		{"https://api.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://.example.org", false},
+		{"https://example.org", false},
+		{"https://review-42.example.net", true},
+		{"https://review-42.example.net.evil.com", false},
+		{"https://review-x.example.net", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed {
+				if got != tt.origin || rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+					t.Errorf("headers = %v, want the origin echoed with credentials", rec.Header())
+				}
+				if rec.Header().Get("Access-Control-Expose-Headers") != "Content-Length, Content-Type" {
+					t.Errorf("expose headers = %q", rec.Header().Get("Access-Control-Expose-Headers"))
+				}
+			} else if got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+			}
+			// 非预检的请求不被拒绝, 由浏览器拦截
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	opts := NewCorsOptions()
+	opts.AllowOrigins = []string{"https://example.com"}
+	opts.MaxAge = time.Hour
+	r := newCorsEngine(t, opts)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/users", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rec.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://example.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS",
+		"Access-Control-Max-Age":       "3600",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("credentials are not allowed")
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 3 {
+		t.Errorf("Vary = %v, want Origin and the request method and headers", vary)
+	}
+
+	if rec := preflight("https://evil.com"); rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
+
+func TestCorsVary(t *testing.T) {
+	opts := NewCorsOptions()
+	opts.AllowOrigins = []string{"https://example.com"}
+	r := newCorsEngine(t, opts)
+
+	// 没有 Origin 的响应也带 Vary, 避免被共享缓存给跨域请求
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("Vary = %q, want Origin", rec.Header().Get("Vary"))
+	}
+
+	// 任意 origin 的响应不随 Origin 变化
+	r = newCorsEngine(t, NewCorsOptions())
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Vary") != "" {
+		t.Errorf("headers = %v, want * without Vary", rec.Header())
+	}
+}
+
+func TestNewCorsRejectsAnyOriginWithCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		origins  []string
+		patterns []string
+	}{
+		{"star", []string{"*"}, nil},
+		{"catch-all pattern", nil, []string{".*"}},
+		{"https pattern", nil, []string{`https://.+`}},
+		{"scheme wildcard", []string{"https://*"}, nil},
+		{"invalid pattern", nil, []string{"("}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewCorsOptions()
+			opts.AllowOrigins = tt.origins
+			opts.AllowOriginPatterns = tt.patterns
+			opts.AllowCredentials = true
+			if _, err := NewCors(opts); err == nil {
+				t.Error("NewCors should fail")
+			}
+		})
+	}
+}
@@ -6,20 +6,19 @@ package middlewares
 
 import (
 	"errors"
-	"time"
 
-	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/chaos-ma/chaos/server/token"
 )
 
 type CustomClaims struct {
 	ID          uint `json:"userid"`
 	NickName    string
 	AuthorityId uint
-	jwt.StandardClaims
+	token.StandardClaims
 }
 
 type JWT struct {
-	SigningKey []byte
+	issuer *token.Issuer
 }
 
 var (
@@ -27,67 +26,52 @@ var (
 	TokenNotValidYet = errors.New("token not active yet")
 	TokenMalformed   = errors.New("that's not even a token")
 	TokenInvalid     = errors.New("couldn't handle this token")
+	TokenRevoked     = errors.New("token has been revoked")
 )
 
+// NewJWT 使用 HS256 签名, 过期时间由 CustomClaims 设置, 默认 1 小时
 func NewJWT(signKey string) *JWT {
-	return &JWT{
-		[]byte(signKey), //可以设置过期时间
-	}
+	return NewJWTWithIssuer(token.NewIssuer(token.NewMemoryKeySet(token.NewHMACKey("", []byte(signKey)))))
+}
+
+// NewJWTWithIssuer 使用 issuer 签发 token, 支持 RS256/ES256/EdDSA 和 kid 轮换
+func NewJWTWithIssuer(issuer *token.Issuer) *JWT {
+	return &JWT{issuer: issuer}
 }
 
 // CreateToken 创建一个token
 func (j *JWT) CreateToken(claims CustomClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.SigningKey)
+	return j.issuer.Issue(&claims)
 }
 
 // ParseToken 解析 token
 func (j *JWT) ParseToken(tokenString string) (*CustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (i interface{}, e error) {
-		return j.SigningKey, nil
-	})
-	if err != nil {
-		var expErr *jwt.TokenExpiredError
-		var nbfErr *jwt.TokenNotValidYetError
-		var fmtErr *jwt.MalformedTokenError
-		if errors.As(err, &fmtErr) {
-			return nil, TokenMalformed
-		} else if errors.As(err, &expErr) {
-			return nil, TokenExpired
-		} else if errors.As(err, &nbfErr) {
-			return nil, TokenNotValidYet
-		} else {
-			return nil, TokenInvalid
-		}
+	claims := &CustomClaims{}
+	if err := j.issuer.Parse(tokenString, claims); err != nil {
+		return nil, convertTokenError(err)
 	}
-	if token != nil {
-		if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-			return claims, nil
-		}
-		return nil, TokenInvalid
-
-	} else {
-		return nil, TokenInvalid
-
-	}
-
+	return claims, nil
 }
 
-// RefreshToken 更新token
+// RefreshToken 更新token, 过期的 token 在 issuer 的 max refresh 时间内也可以刷新
 func (j *JWT) RefreshToken(tokenString string) (string, error) {
-	jwt.TimeFunc = func() time.Time {
-		return time.Unix(0, 0)
-	}
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SigningKey, nil
-	})
+	tokenString, err := j.issuer.Refresh(tokenString, &CustomClaims{})
 	if err != nil {
-		return "", err
+		return "", convertTokenError(err)
 	}
-	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-		jwt.TimeFunc = time.Now
-		claims.StandardClaims.ExpiresAt = jwt.At(time.Now().Add(1 * time.Hour))
-		return j.CreateToken(*claims)
+	return tokenString, nil
+}
+
+func convertTokenError(err error) error {
+	switch {
+	case errors.Is(err, token.ErrTokenMalformed):
+		return TokenMalformed
+	case errors.Is(err, token.ErrTokenExpired), errors.Is(err, token.ErrRefreshExpired):
+		return TokenExpired
+	case errors.Is(err, token.ErrTokenNotValidYet):
+		return TokenNotValidYet
+	case errors.Is(err, token.ErrTokenRevoked):
+		return TokenRevoked
 	}
-	return "", TokenInvalid
+	return TokenInvalid
 }
@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chaos-ma/chaos/core/metric"
+)
+
+// 与 rpc_server_requests_* 保持同样的命名, 便于 http 和 grpc 使用同一套看板
+const serverNamespace = "http_server"
+
+// unmatchedRoute is the route label of the requests matching no route, it keeps the label cardinality bounded.
+const unmatchedRoute = "unmatched"
+
+// MetricsOptions is the http server metrics configuration.
+type MetricsOptions struct {
+	// Namespace defaults to http_server
+	Namespace string `json:"namespace" mapstructure:"namespace"`
+	// Buckets of the duration histogram in milliseconds
+	Buckets []float64 `json:"buckets" mapstructure:"buckets"`
+	// Service is the value of the service label, the label is absent if empty
+	Service string `json:"service" mapstructure:"service"`
+	// Registry defaults to metric.DefaultRegistry()
+	Registry metric.Registry `json:"-" mapstructure:"-"`
+}
+
+// NewMetricsOptions returns the default metrics options, the buckets are the same as the rpc server.
+func NewMetricsOptions() *MetricsOptions {
+	return &MetricsOptions{
+		Namespace: serverNamespace,
+		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
+	}
+}
+
+type httpMetrics struct {
+	duration metric.HistogramVec
+	total    metric.CounterVec
+	errors   metric.CounterVec
+}
+
+func newHTTPMetrics(opts *MetricsOptions) *httpMetrics {
+	// service 为空时 prometheus 视为没有这个 label
+	labels := []string{"service", "method", "route", "code"}
+	reg := opts.Registry
+	if reg == nil {
+		reg = metric.DefaultRegistry()
+	}
+
+	// 同一个 registry 中已经注册的指标会被复用, 以第一次的 buckets 为准
+	return &httpMetrics{
+		duration: reg.NewHistogramVec(&metric.HistogramVecOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "requests",
+			Name:      "chaos_duration_ms",
+			Help:      "http server requests duration(ms).",
+			Labels:    labels,
+			Buckets:   opts.Buckets,
+		}),
+		total: reg.NewCounterVec(&metric.CounterVecOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "requests",
+			Name:      "chaos_code_total",
+			Help:      "http server requests code count.",
+			Labels:    labels,
+		}),
+		errors: reg.NewCounterVec(&metric.CounterVecOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "requests",
+			Name:      "chaos_errors_total",
+			Help:      "http server requests error(5xx) count.",
+			Labels:    labels,
+		}),
+	}
+}
+
+// Metrics records the requests, errors and duration by method, route template and status code.
+func Metrics(opts *MetricsOptions) gin.HandlerFunc {
+	if opts == nil {
+		opts = NewMetricsOptions()
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = serverNamespace
+	}
+	m := newHTTPMetrics(opts)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		// tracing 中间件在 c.Next 返回后恢复原来的 context, 先保存带 span 的 context
+		ctx := c.Request.Context()
+		c.Next()
+
+		// 使用路由模板而不是实际的路径, 避免 /user/:id 产生大量的 label
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := c.Writer.Status()
+		labels := []string{opts.Service, c.Request.Method, route, strconv.Itoa(status)}
+
+		m.duration.ObserveContext(ctx, float64(time.Since(start))/float64(time.Millisecond), labels...)
+		m.total.Inc(labels...)
+		if status >= 500 {
+			m.errors.Inc(labels...)
+		}
+	}
+}
@@ -5,15 +5,182 @@ package middlewares
  */
 
 import (
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/gin-gonic/gin"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/chaos-ma/chaos/errors"
 )
 
-var Middlewares = defaultMiddlewares()
+// 中间件的默认优先级, 数字越小越先执行
+const (
+	PriorityRecovery = 0
+	// context 在日志之前生成 request id
+	PriorityContext = 10
+	PriorityTracing = 100
+	PriorityBaggage = 110
+	// metrics 在 tracing 之内, exemplar 才能拿到 span
+	PriorityMetrics = 120
+	PriorityDefault = 200
+)
+
+// Config is the parameters of a middleware instance.
+type Config map[string]interface{}
+
+// Decode decodes the config into out by mapstructure tags, durations can be strings like "10s".
+func (c Config) Decode(out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(map[string]interface{}(c))
+}
+
+// Factory creates a middleware with the config, it may panic if the config is invalid,
+// the panic fails the startup.
+type Factory func(cfg Config) gin.HandlerFunc
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Middlewares are the middlewares without config, the names not registered by Register are looked up here.
+//
+// Deprecated: use Register, the factory registered by the same name takes precedence.
+var Middlewares = map[string]gin.HandlerFunc{
+	"recovery": gin.Recovery(),
+	"cors":     Cors(),
+	"context":  Context(),
+}
+
+// lookup returns the factory of name, the caller holds mu.
+func lookup(name string) (Factory, bool) {
+	if f, ok := factories[name]; ok {
+		return f, true
+	}
+	if h, ok := Middlewares[name]; ok && h != nil {
+		return func(Config) gin.HandlerFunc { return h }, true
+	}
+	return nil, false
+}
+
+// Register registers a middleware factory, the later one replaces the former one of the same name.
+func Register(name string, factory Factory) {
+	if name == "" || factory == nil {
+		panic("middlewares: register with empty name or nil factory")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Registered returns the registered middleware names.
+func Registered() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories)+len(Middlewares))
+	for name := range factories {
+		names = append(names, name)
+	}
+	for name := range Middlewares {
+		if _, ok := factories[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("recovery", func(Config) gin.HandlerFunc { return gin.Recovery() })
+	Register("context", func(Config) gin.HandlerFunc { return Context() })
+	Register("cors", func(cfg Config) gin.HandlerFunc {
+		opts := NewCorsOptions()
+		if err := cfg.Decode(opts); err != nil {
+			panic(err)
+		}
+		return CorsWithOptions(opts)
+	})
+}
+
+// Spec is a middleware instance, e.g. in yaml:
+//
+//	middlewares:
+//	  - name: cors
+//	    priority: 50
+//	    group: /api
+//	    config:
+//	      allow-origins: ["https://*.example.com"]
+type Spec struct {
+	Name string `json:"name" mapstructure:"name"`
+	// Priority orders the middlewares, the smaller runs first, the tracing middleware is PriorityTracing,
+	// so a spec without priority(zero) runs before tracing and sees no span, set PriorityDefault to run after it
+	Priority int `json:"priority" mapstructure:"priority"`
+	// Group scopes the middleware to the routes under the path prefix, empty means all routes
+	Group  string `json:"group"  mapstructure:"group"`
+	Config Config `json:"config" mapstructure:"config"`
+}
+
+// Handler is a built middleware with its priority.
+type Handler struct {
+	Name     string
+	Priority int
+	Handler  gin.HandlerFunc
+}
+
+// Build creates the middlewares of specs ordered by priority, the specs of
+// the same priority keep their order, unknown names are errors.
+func Build(specs ...Spec) (handlers []Handler, err error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, spec := range specs {
+		factory, ok := lookup(spec.Name)
+		if !ok {
+			return nil, errors.Errorf("can not find middleware: %s", spec.Name)
+		}
+		h, err := build(spec, factory)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, Handler{Name: spec.Name, Priority: spec.Priority, Handler: h})
+	}
+	sort.SliceStable(handlers, func(i, j int) bool { return handlers[i].Priority < handlers[j].Priority })
+	return handlers, nil
+}
+
+func build(spec Spec, factory Factory) (h gin.HandlerFunc, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("create middleware %s: %v", spec.Name, r)
+		}
+	}()
+	if spec.Config == nil {
+		spec.Config = Config{}
+	}
+	h = factory(spec.Config)
+	if h == nil {
+		return nil, errors.Errorf("middleware %s factory returns nil", spec.Name)
+	}
+	if spec.Group == "" || spec.Group == "/" {
+		return h, nil
+	}
+	return scoped(strings.TrimSuffix(spec.Group, "/"), h), nil
+}
 
-func defaultMiddlewares() map[string]gin.HandlerFunc {
-	return map[string]gin.HandlerFunc{
-		"recovery": gin.Recovery(),
-		"cors":     Cors(),
-		"context":  Context(),
+// scoped runs h only for the paths under prefix.
+func scoped(prefix string, h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			h(c)
+		}
 	}
 }
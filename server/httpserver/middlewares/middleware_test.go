@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBuildDeprecatedMiddlewares(t *testing.T) {
+	Middlewares["legacy"] = func(c *gin.Context) {
+		c.Header("X-Legacy", "1")
+	}
+	defer delete(Middlewares, "legacy")
+
+	handlers, err := Build(Spec{Name: "legacy", Priority: PriorityDefault}, Spec{Name: "recovery"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handlers) != 2 || handlers[0].Name != "recovery" || handlers[1].Name != "legacy" {
+		t.Fatalf("handlers = %v, want recovery then legacy", handlers)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handlers[1].Handler)
+	r.GET("/", func(c *gin.Context) {})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("X-Legacy") != "1" {
+		t.Error("the middleware of the deprecated map is not installed")
+	}
+
+	found := false
+	for _, name := range Registered() {
+		found = found || name == "legacy"
+	}
+	if !found {
+		t.Error("Registered does not list the deprecated middleware")
+	}
+}
@@ -4,6 +4,13 @@ package httpserver
 * created by mengqi on 2023/11/21
  */
 
+import (
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/server/health"
+	mws "github.com/chaos-ma/chaos/server/httpserver/middlewares"
+	"github.com/chaos-ma/chaos/server/tlsconfig"
+)
+
 type ServerOption func(*Server)
 
 func WithEnableProfiling(profiling bool) ServerOption {
@@ -30,18 +37,42 @@ func WithPort(port int) ServerOption {
 	}
 }
 
+// WithMiddlewares installs the registered middlewares by name after tracing, in the given order.
 func WithMiddlewares(middlewares []string) ServerOption {
 	return func(s *Server) {
 		s.middlewares = middlewares
 	}
 }
 
+// WithMiddlewareSpecs installs the registered middlewares with config, priority and group,
+// the unknown names make Start fail.
+func WithMiddlewareSpecs(specs ...mws.Spec) ServerOption {
+	return func(s *Server) {
+		s.middlewareSpecs = append(s.middlewareSpecs, specs...)
+	}
+}
+
 func WithHealthz(healthz bool) ServerOption {
 	return func(s *Server) {
 		s.healthz = healthz
 	}
 }
 
+// WithHealth serves the checks of h on /health, /ready and /live, share h with the rpc server
+// so both of them report the same dependencies.
+func WithHealth(h *health.Health) ServerOption {
+	return func(s *Server) {
+		s.health = h
+	}
+}
+
+// WithHealthVerbose shows the check details and errors on the probes, see health.WithVerbose.
+func WithHealthVerbose(verbose bool) ServerOption {
+	return func(s *Server) {
+		s.healthVerbose = verbose
+	}
+}
+
 func WithJwt(jwt *JwtInfo) ServerOption {
 	return func(s *Server) {
 		s.jwt = jwt
@@ -59,3 +90,40 @@ func WithMetrics(enable bool) ServerOption {
 		o.enableMetrics = enable
 	}
 }
+
+// WithMetricRegistry records the metrics to reg and serves reg on /metrics,
+// /metrics returns 404 for a metric.OTelRegistry which pushes the metrics by OTLP.
+func WithMetricRegistry(reg metric.Registry) ServerOption {
+	return func(s *Server) {
+		s.metrics = reg
+	}
+}
+
+// WithMetricsOptions sets the namespace, buckets and service label of the http metrics.
+func WithMetricsOptions(opts *mws.MetricsOptions) ServerOption {
+	return func(s *Server) {
+		s.metricsOpts = opts
+	}
+}
+
+// WithTLSConfig serves https, and the endpoint is registered with isSecure=true.
+func WithTLSConfig(opts *tlsconfig.Options) ServerOption {
+	return func(s *Server) {
+		s.tlsOpts = opts
+	}
+}
+
+// WithCors installs the cors middleware with opts, the "cors" in WithMiddlewares is ignored then.
+func WithCors(opts *mws.CorsOptions) ServerOption {
+	return func(s *Server) {
+		s.cors = opts
+	}
+}
+
+// WithAdminMode keeps the health, pprof and metrics routes off the public listener,
+// serve them by the admin server on a separate port instead.
+func WithAdminMode(enable bool) ServerOption {
+	return func(s *Server) {
+		s.adminMode = enable
+	}
+}
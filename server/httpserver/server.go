@@ -9,16 +9,23 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	ut "github.com/go-playground/universal-translator"
-	"github.com/penglongli/gin-metrics/ginmetrics"
 
+	"github.com/chaos-ma/chaos/core/metric"
 	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/server/health"
 	mws "github.com/chaos-ma/chaos/server/httpserver/middlewares"
 	"github.com/chaos-ma/chaos/server/httpserver/pprof"
 	"github.com/chaos-ma/chaos/server/httpserver/validation"
+	"github.com/chaos-ma/chaos/server/rpcserver/resolver/discovery"
+	"github.com/chaos-ma/chaos/server/tlsconfig"
+	"github.com/chaos-ma/chaos/server/token"
+	"github.com/chaos-ma/chaos/utils/host"
 )
 
 type JwtInfo struct {
@@ -30,22 +37,38 @@ type JwtInfo struct {
 	Timeout time.Duration
 	// defaults to 7 days
 	MaxRefresh time.Duration
+	// Keys signs tokens with RS256/ES256/EdDSA keys instead of Key,
+	// the public keys are served on /.well-known/jwks.json
+	Keys *token.MemoryKeySet
+	// Revoker rejects the revoked tokens, defaults to nil
+	Revoker *token.Revoker
 }
 
 // Server wrapper for gin.Engine
 type Server struct {
 	*gin.Engine
-	port            int      //端口号， 默认值 8080
-	mode            string   //开发模式， 默认值 debug
-	healthz         bool     //是否开启健康检查接口， 默认开启， 如果开启会自动添加 /health 接口
+	port            int    //端口号， 默认值 8080
+	mode            string //开发模式， 默认值 debug
+	healthz         bool   //是否开启健康检查接口， 默认开启， 如果开启会自动添加 /health /ready /live 接口, 已注册的路由保持不变
+	health          *health.Health
+	healthVerbose   bool     //健康检查接口是否输出检查的详情, 默认关闭
 	enableProfiling bool     //是否开启pprof接口， 默认开启， 如果开启会自动添加 /debug/pprof 接口
 	enableMetrics   bool     //是否开启metrics接口， 默认开启， 如果开启会自动添加 /metrics 接口
 	middlewares     []string //中间件
+	middlewareSpecs []mws.Spec
+	err             error    //初始化中间件的错误, Start 时返回
 	jwt             *JwtInfo //jwt配置信息
 	transName       string   //翻译器, 默认值 zh
 	trans           ut.Translator
 	server          *http.Server
 	serviceName     string
+	tlsOpts         *tlsconfig.Options //tls配置, 设置后使用https
+	tls             *tlsconfig.Reloader
+	issuer          *token.Issuer
+	cors            *mws.CorsOptions //cors配置, 设置后替代名为 cors 的中间件
+	adminMode       bool             //运维接口由 admin server 提供, 不在业务端口暴露
+	metricsOpts     *mws.MetricsOptions
+	metrics         metric.Registry
 }
 
 func NewServer(opts ...ServerOption) *Server {
@@ -55,34 +78,110 @@ func NewServer(opts ...ServerOption) *Server {
 		healthz:         true,
 		enableProfiling: true,
 		jwt: &JwtInfo{
-			"JWT",
-			"GUeLB4rcX7LEus2rkeWuBPrZwNdR7pkV",
-			7 * 24 * time.Hour,
-			7 * 24 * time.Hour,
+			Realm:      "JWT",
+			Key:        defaultJwtKey,
+			Timeout:    7 * 24 * time.Hour,
+			MaxRefresh: 7 * 24 * time.Hour,
 		},
 		Engine:      gin.Default(),
 		transName:   "zh",
 		serviceName: "chaos",
+		metrics:     metric.DefaultRegistry(),
 	}
 
 	for _, o := range opts {
 		o(srv)
 	}
 
-	srv.Use(mws.TracingHandler(srv.serviceName))
-	for _, m := range srv.middlewares {
-		mw, ok := mws.Middlewares[m]
-		if !ok {
-			log.Warnf("can not find middleware: %s", m)
+	srv.issuer = srv.newIssuer()
+
+	srv.err = srv.installMiddlewares()
+
+	// 路由在中间件之后注册, 才会经过中间件
+	if srv.jwt.Keys != nil {
+		srv.GET(token.JWKSPath, gin.WrapH(srv.jwt.Keys))
+	}
+
+	return srv
+}
+
+func (s *Server) installMiddlewares() error {
+	specs := make([]mws.Spec, 0, len(s.middlewares)+len(s.middlewareSpecs)+1)
+	for _, m := range s.middlewares {
+		if m == "cors" && s.cors != nil {
 			continue
-			//panic(errors.Errorf("can not find middleware: %s", m))
 		}
+		specs = append(specs, mws.Spec{Name: m, Priority: mws.PriorityDefault})
+	}
+	specs = append(specs, s.middlewareSpecs...)
+	// 默认安装 context, 日志才有 request id
+	hasContext := false
+	for _, spec := range specs {
+		hasContext = hasContext || spec.Name == "context"
+	}
+	if !hasContext {
+		specs = append(specs, mws.Spec{Name: "context", Priority: mws.PriorityContext})
+	}
 
-		log.Infof("intall middleware: %s", m)
-		srv.Use(mw)
+	handlers, err := mws.Build(specs...)
+	if err != nil {
+		log.Errorf("[httpserver] failed to build middlewares: %s", err)
+		return err
 	}
+	handlers = append(handlers, mws.Handler{Name: "tracing", Priority: mws.PriorityTracing, Handler: mws.TracingHandler(s.serviceName)})
+	handlers = append(handlers, mws.Handler{Name: "baggage", Priority: mws.PriorityBaggage, Handler: mws.Baggage()})
+	if s.enableMetrics {
+		opts := s.metricsOpts
+		if opts == nil {
+			opts = mws.NewMetricsOptions()
+		}
+		if opts.Registry == nil {
+			opts.Registry = s.metrics
+		}
+		handlers = append(handlers, mws.Handler{Name: "metrics", Priority: mws.PriorityMetrics, Handler: mws.Metrics(opts)})
+	}
+	if s.cors != nil {
+		cors, err := mws.NewCors(s.cors)
+		if err != nil {
+			log.Errorf("[httpserver] failed to create cors middleware: %s", err)
+			return err
+		}
+		handlers = append(handlers, mws.Handler{Name: "cors", Priority: mws.PriorityDefault, Handler: cors})
+	}
+	sort.SliceStable(handlers, func(i, j int) bool { return handlers[i].Priority < handlers[j].Priority })
 
-	return srv
+	for _, h := range handlers {
+		log.Infof("install middleware: %s", h.Name)
+		s.Use(h.Handler)
+	}
+	return nil
+}
+
+const defaultJwtKey = "GUeLB4rcX7LEus2rkeWuBPrZwNdR7pkV"
+
+func (s *Server) newIssuer() *token.Issuer {
+	opts := []token.IssuerOption{
+		token.WithTTL(s.jwt.Timeout),
+		token.WithMaxRefresh(s.jwt.MaxRefresh),
+		token.WithRevoker(s.jwt.Revoker),
+	}
+	if s.jwt.Keys != nil {
+		return token.NewIssuer(s.jwt.Keys, opts...)
+	}
+	if s.jwt.Key == defaultJwtKey {
+		log.Warnf("[httpserver] jwt key is the default one, set it by WithJwt in production")
+	}
+	return token.NewIssuer(token.NewMemoryKeySet(token.NewHMACKey("", []byte(s.jwt.Key))), opts...)
+}
+
+// Issuer returns the jwt issuer configured by WithJwt.
+func (s *Server) Issuer() *token.Issuer {
+	return s.issuer
+}
+
+// Health returns the health of the server, it is nil before Start if WithHealth is not set.
+func (s *Server) Health() *health.Health {
+	return s.health
 }
 
 func (s *Server) Translator() ut.Translator {
@@ -91,6 +190,10 @@ func (s *Server) Translator() ut.Translator {
 
 // start rest server
 func (s *Server) Start(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+
 	//设置开发模式，打印路由信息
 	if s.mode != gin.DebugMode && s.mode != gin.ReleaseMode && s.mode != gin.TestMode {
 		return errors.New("mode must be one of debug/release/test")
@@ -112,21 +215,28 @@ func (s *Server) Start(ctx context.Context) error {
 	//注册mobile验证码
 	validation.RegisterMobile(s.trans)
 
+	if s.healthz {
+		if s.health == nil {
+			s.health = health.New()
+		}
+		s.health.Resume()
+		if !s.adminMode {
+			var opts []health.RouteOption
+			if s.healthVerbose {
+				opts = append(opts, health.WithVerbose())
+			}
+			s.health.RegisterRoutes(s.Engine, opts...)
+		}
+	}
+
 	//根据配置初始化pprof路由
-	if s.enableProfiling {
+	if s.enableProfiling && !s.adminMode {
 		pprof.Register(s.Engine)
 	}
 
-	if s.enableMetrics {
-		// get global Monitor object
-		m := ginmetrics.GetMonitor()
-		// +optional set metric path, default /debug/metrics
-		m.SetMetricPath("/metrics")
-		// +optional set slow time, default 5s
-		// +optional set request duration, default {0.1, 0.3, 1.2, 5, 10}
-		// used to p95, p99
-		m.SetDuration([]float64{0.1, 0.3, 1.2, 5, 10})
-		m.Use(s)
+	//指标由 metrics 中间件采集, /metrics 暴露指标所在的 registry
+	if s.enableMetrics && !s.adminMode {
+		s.GET("/metrics", gin.WrapH(s.metrics.Handler()))
 	}
 
 	log.Infof("rest server is running on port: %d", s.port)
@@ -136,14 +246,40 @@ func (s *Server) Start(ctx context.Context) error {
 		Handler: s.Engine,
 	}
 	_ = s.SetTrustedProxies(nil)
-	if err = s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if s.tlsOpts != nil {
+		s.tls, err = tlsconfig.NewReloader(s.tlsOpts)
+		if err != nil {
+			return err
+		}
+		s.server.TLSConfig = s.tls.ServerConfig()
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// Endpoint returns the registered endpoint, secure endpoint has isSecure=true.
+func (s *Server) Endpoint() (*url.URL, error) {
+	addr, err := host.Extract(fmt.Sprintf(":%d", s.port), nil)
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewEndpoint("http", addr, s.tlsOpts != nil), nil
+}
+
 func (s *Server) Stop(ctx context.Context) error {
 	log.Infof("rest server is stopping")
+	//先让 /ready 返回 503, 负载均衡摘除流量
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+	if s.tls != nil {
+		s.tls.Stop()
+	}
 	if err := s.server.Shutdown(ctx); err != nil {
 		log.Errorf("rest server shutdown error: %s", err.Error())
 		return err
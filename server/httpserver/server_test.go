@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/log"
+)
+
+func TestMetricsExemplarHasTraceID(t *testing.T) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	defer tp.Shutdown(context.Background())
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	reg := metric.NewRegistry()
+	s := NewServer(WithMode(gin.TestMode), WithMetrics(true), WithMetricRegistry(reg))
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	var traceID string
+	s.GET("/users/:id", func(c *gin.Context) {
+		traceID = trace.SpanContextFromContext(c.Request.Context()).TraceID().String()
+		c.Status(http.StatusOK)
+	})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if traceID == "" || traceID == (trace.TraceID{}).String() {
+		t.Fatal("the handler has no span")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, req)
+
+	// 例如 http_server_requests_chaos_duration_ms_bucket{...} 1 # {span_id="...",trace_id="..."} 0.1
+	exemplar := regexp.MustCompile(`http_server_requests_chaos_duration_ms_bucket\{[^}]*route="/users/:id"[^}]*\} \d+ # \{[^}]*trace_id="` + traceID + `"`)
+	if !exemplar.MatchString(rec.Body.String()) {
+		t.Errorf("no exemplar with trace_id %s in:\n%s", traceID, rec.Body.String())
+	}
+}
+
+func TestRequestIDByDefault(t *testing.T) {
+	s := NewServer(WithMode(gin.TestMode), WithMetrics(false))
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	var logged string
+	s.GET("/ping", func(c *gin.Context) {
+		logged, _ = log.RequestIDFromContext(c)
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"kept", "req-1", true},
+		{"invalid replaced", "bad id\r\nlevel=error", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			if tt.incoming != "" {
+				req.Header.Set(log.RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(log.RequestIDHeader)
+			if echoed == "" || echoed != logged {
+				t.Fatalf("echoed %q, logged %q, want the same request id", echoed, logged)
+			}
+			if (echoed == tt.incoming) != tt.keep {
+				t.Errorf("request id = %q, incoming %q, keep = %v", echoed, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ package rpcserver
  */
 
 import (
+	"sync"
+
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
 	"google.golang.org/grpc/metadata"
@@ -55,9 +57,28 @@ func (b *balancerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
 		selector: b.builder.Build(),
 	}
 	p.selector.Apply(nodes)
+	if name := nodes[0].ServiceName(); name != "" {
+		pickers.Store(name, p)
+	}
 	return p
 }
 
+// pickers is the latest picker of every service, for debugging.
+var pickers sync.Map
+
+// SelectorState returns the node state of the selectors by service name,
+// the selectors which do not implement Snapshot are omitted.
+func SelectorState() map[string][]selector.NodeState {
+	state := make(map[string][]selector.NodeState)
+	pickers.Range(func(key, value interface{}) bool {
+		if s, ok := value.(*balancerPicker).selector.(interface{ Snapshot() []selector.NodeState }); ok {
+			state[key.(string)] = s.Snapshot()
+		}
+		return true
+	})
+	return state
+}
+
 // balancerPicker is a grpc picker.
 type balancerPicker struct {
 	selector selector.Selector
@@ -10,12 +10,16 @@ import (
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	grpcinsecure "google.golang.org/grpc/credentials/insecure"
 
+	"github.com/chaos-ma/chaos/core/metric"
 	"github.com/chaos-ma/chaos/log"
 	"github.com/chaos-ma/chaos/registry"
 	"github.com/chaos-ma/chaos/server/rpcserver/clientinterceptors"
 	"github.com/chaos-ma/chaos/server/rpcserver/resolver/discovery"
+	"github.com/chaos-ma/chaos/server/tlsconfig"
 )
 
 type ClientOption func(o *clientOptions)
@@ -32,6 +36,10 @@ type clientOptions struct {
 	log           log.LogHelper
 	enableTracing bool
 	enableMetrics bool
+	metrics       metric.Registry
+	hedgingOpts   []clientinterceptors.HedgingOption
+	tlsOpts       *tlsconfig.Options
+	creds         credentials.PerRPCCredentials
 }
 
 func WithEnableTracing(enable bool) ClientOption {
@@ -40,6 +48,13 @@ func WithEnableTracing(enable bool) ClientOption {
 	}
 }
 
+// WithClientMetricRegistry records the client metrics to reg instead of the default registry.
+func WithClientMetricRegistry(reg metric.Registry) ClientOption {
+	return func(o *clientOptions) {
+		o.metrics = reg
+	}
+}
+
 func WithEndpoint(endpoint string) ClientOption {
 	return func(o *clientOptions) {
 		o.endpoint = endpoint
@@ -76,6 +91,28 @@ func WithClientOptions(opts ...grpc.DialOption) ClientOption {
 	}
 }
 
+// WithHedging sends hedged requests for the opted in methods,
+// use it with the selector balancer to hedge on different nodes.
+func WithHedging(opts ...clientinterceptors.HedgingOption) ClientOption {
+	return func(o *clientOptions) {
+		o.hedgingOpts = opts
+	}
+}
+
+// WithClientTLSConfig dials the secure endpoints with tls, the client certificate is used by mTLS.
+func WithClientTLSConfig(opts *tlsconfig.Options) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsOpts = opts
+	}
+}
+
+// WithPerRPCCredentials attaches the credentials to every rpc, like clientinterceptors.TokenCredentials.
+func WithPerRPCCredentials(creds credentials.PerRPCCredentials) ClientOption {
+	return func(o *clientOptions) {
+		o.creds = creds
+	}
+}
+
 func WithBalancerName(name string) ClientOption {
 	return func(o *clientOptions) {
 		o.balancerName = name
@@ -104,16 +141,27 @@ func dial(ctx context.Context, insecure bool, opts ...ClientOption) (*grpc.Clien
 	// 客户端默认拦截器
 	ints := []grpc.UnaryClientInterceptor{
 		clientinterceptors.TimeoutInterceptor(options.timeout),
+		clientinterceptors.UnaryRequestIDInterceptor,
 	}
 	if options.enableTracing {
 		ints = append(ints, otelgrpc.UnaryClientInterceptor())
 	}
 
+	if options.metrics == nil {
+		options.metrics = metric.DefaultRegistry()
+	}
 	if options.enableMetrics {
-		ints = append(ints, clientinterceptors.PrometheusInterceptor())
+		ints = append(ints, clientinterceptors.PrometheusInterceptorWithRegistry(options.metrics))
 	}
 
-	streamInts := []grpc.StreamClientInterceptor{}
+	if len(options.hedgingOpts) > 0 {
+		hedgingOpts := append([]clientinterceptors.HedgingOption{clientinterceptors.WithHedgingRegistry(options.metrics)}, options.hedgingOpts...)
+		ints = append(ints, clientinterceptors.HedgingInterceptor(hedgingOpts...))
+	}
+
+	streamInts := []grpc.StreamClientInterceptor{
+		clientinterceptors.StreamRequestIDInterceptor,
+	}
 
 	if len(options.unaryInts) > 0 {
 		ints = append(ints, options.unaryInts...)
@@ -121,6 +169,9 @@ func dial(ctx context.Context, insecure bool, opts ...ClientOption) (*grpc.Clien
 	if len(options.streamInts) > 0 {
 		streamInts = append(streamInts, options.streamInts...)
 	}
+	// baggage 放在最后, 替换掉 tracing 拦截器注入的未过滤的 baggage
+	ints = append(ints, clientinterceptors.UnaryBaggageInterceptor)
+	streamInts = append(streamInts, clientinterceptors.StreamBaggageInterceptor)
 
 	grpcOpts := []grpc.DialOption{
 		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy": "` + options.balancerName + `"}`),
@@ -138,13 +189,41 @@ func dial(ctx context.Context, insecure bool, opts ...ClientOption) (*grpc.Clien
 		))
 	}
 
+	var reloader *tlsconfig.Reloader
 	if insecure {
 		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(grpcinsecure.NewCredentials()))
+	} else if options.tlsOpts != nil {
+		r, err := tlsconfig.NewReloader(options.tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		reloader = r
+		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(r.ClientCredentials()))
+	}
+
+	if options.creds != nil {
+		grpcOpts = append(grpcOpts, grpc.WithPerRPCCredentials(options.creds))
 	}
 
 	if len(options.rpcOpts) > 0 {
 		grpcOpts = append(grpcOpts, options.rpcOpts...)
 	}
 
-	return grpc.DialContext(ctx, options.endpoint, grpcOpts...)
+	conn, err := grpc.DialContext(ctx, options.endpoint, grpcOpts...)
+	if reloader != nil {
+		if err != nil {
+			reloader.Stop()
+			return nil, err
+		}
+		go stopOnClose(conn, reloader)
+	}
+	return conn, err
+}
+
+// stopOnClose stops the tls reloader after the conn is closed.
+func stopOnClose(conn *grpc.ClientConn, r *tlsconfig.Reloader) {
+	for state := conn.GetState(); state != connectivity.Shutdown; state = conn.GetState() {
+		conn.WaitForStateChange(context.Background(), state)
+	}
+	r.Stop()
 }
@@ -0,0 +1,85 @@
+package clientinterceptors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TokenSource mints a token which expires at expiresAt.
+type TokenSource func(ctx context.Context) (token string, expiresAt time.Time, err error)
+
+var _ credentials.PerRPCCredentials = (*TokenCredentials)(nil)
+
+// TokenCredentials puts the bearer token into the authorization metadata of every rpc,
+// the token is minted again before it expires.
+type TokenCredentials struct {
+	source        TokenSource
+	refreshBefore time.Duration
+	requireTLS    bool
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// TokenCredentialsOption is token credentials option.
+type TokenCredentialsOption func(c *TokenCredentials)
+
+// WithRefreshBefore mints a new token d before the current one expires.
+func WithRefreshBefore(d time.Duration) TokenCredentialsOption {
+	return func(c *TokenCredentials) {
+		c.refreshBefore = d
+	}
+}
+
+// WithRequireTLS sets whether the token is only sent over tls, defaults to true.
+func WithRequireTLS(require bool) TokenCredentialsOption {
+	return func(c *TokenCredentials) {
+		c.requireTLS = require
+	}
+}
+
+// NewTokenCredentials creates per rpc credentials with the token source.
+func NewTokenCredentials(source TokenSource, opts ...TokenCredentialsOption) *TokenCredentials {
+	c := &TokenCredentials{
+		source:        source,
+		refreshBefore: 30 * time.Second,
+		requireTLS:    true,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// GetRequestMetadata returns the authorization metadata.
+func (c *TokenCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity indicates whether the credentials requires transport security.
+func (c *TokenCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// Token returns the cached token, or mints a new one if it is about to expire.
+func (c *TokenCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Add(c.refreshBefore).Before(c.expiresAt) {
+		return c.token, nil
+	}
+	token, expiresAt, err := c.source(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expiresAt = token, expiresAt
+	return token, nil
+}
@@ -0,0 +1,86 @@
+package clientinterceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestTokenCredentialsRefresh(t *testing.T) {
+	var minted int
+	now := time.Now()
+	creds := NewTokenCredentials(func(ctx context.Context) (string, time.Time, error) {
+		minted++
+		// 第一个 token 即将过期, 第二个有效期一小时
+		if minted == 1 {
+			return "t1", now.Add(10 * time.Second), nil
+		}
+		return "t2", now.Add(time.Hour), nil
+	}, WithRefreshBefore(30*time.Second))
+
+	for _, want := range []string{"t1", "t2", "t2"} {
+		md, err := creds.GetRequestMetadata(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md["authorization"] != "Bearer "+want {
+			t.Errorf("authorization = %q, want Bearer %s", md["authorization"], want)
+		}
+	}
+	if minted != 2 {
+		t.Errorf("minted = %d, want 2", minted)
+	}
+}
+
+func TestTokenCredentialsTransportSecurity(t *testing.T) {
+	source := func(ctx context.Context) (string, time.Time, error) {
+		return "t1", time.Now().Add(time.Hour), nil
+	}
+	if !NewTokenCredentials(source).RequireTransportSecurity() {
+		t.Fatal("tls should be required by default")
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	authorization := make(chan string, 1)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorization <- md.Get("authorization")[0]
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+	dial := func(creds *TokenCredentials) (*grpc.ClientConn, error) {
+		return grpc.Dial("passthrough:///bufconn",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithPerRPCCredentials(creds))
+	}
+
+	// 默认不在明文连接上发送 token
+	if conn, err := dial(NewTokenCredentials(source)); err == nil {
+		conn.Close()
+		t.Fatal("dial without tls should fail")
+	}
+
+	conn, err := dial(NewTokenCredentials(source, WithRequireTLS(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-authorization; got != "Bearer t1" {
+		t.Errorf("authorization = %q, want Bearer t1", got)
+	}
+}
@@ -0,0 +1,34 @@
+package clientinterceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/core/baggage"
+)
+
+func withBaggage(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	baggage.InjectMetadata(ctx, md)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// UnaryBaggageInterceptor propagates the allowed baggage members of the context, it replaces the
+// baggage injected by the tracing interceptors, so it must be the last one of the chain.
+func UnaryBaggageInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(withBaggage(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamBaggageInterceptor is the stream version of UnaryBaggageInterceptor.
+func StreamBaggageInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(withBaggage(ctx), desc, cc, method, opts...)
+}
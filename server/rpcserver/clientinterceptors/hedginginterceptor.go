@@ -0,0 +1,365 @@
+package clientinterceptors
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/server/rpcserver/selector"
+)
+
+const (
+	// latencyWindow is the number of latest latencies used to learn the p95.
+	latencyWindow = 256
+	// latencyRefresh is the number of observations between two p95 calculations.
+	latencyRefresh = 64
+)
+
+func newHedgeMetric(reg metric.Registry) metric.CounterVec {
+	return reg.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: serverNamespace,
+		Subsystem: "hedging",
+		Name:      "chaos_total",
+		Help:      "rpc client hedged requests count.",
+		Labels:    []string{"method", "result"},
+	})
+}
+
+// HedgingOption is hedging interceptor option.
+type HedgingOption func(o *hedgingOptions)
+
+type hedgingOptions struct {
+	delay       time.Duration
+	learnP95    bool
+	maxAttempts int
+	// budget
+	ratio     float64
+	maxTokens float64
+
+	methods map[string]struct{}
+	option  protoreflect.ExtensionType
+
+	registry metric.Registry
+}
+
+// WithHedgingDelay sets the delay before sending the next hedged request.
+func WithHedgingDelay(delay time.Duration) HedgingOption {
+	return func(o *hedgingOptions) {
+		o.delay = delay
+	}
+}
+
+// WithHedgingP95 uses the learned p95 latency of the method as the delay,
+// the configured delay is used until enough latencies are observed.
+func WithHedgingP95(enable bool) HedgingOption {
+	return func(o *hedgingOptions) {
+		o.learnP95 = enable
+	}
+}
+
+// WithHedgingMaxAttempts sets the max requests sent for one call, including the original one.
+func WithHedgingMaxAttempts(n int) HedgingOption {
+	return func(o *hedgingOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithHedgingBudget limits the hedged requests, every call earns ratio tokens,
+// every hedged request costs one token, and at most maxTokens are saved.
+func WithHedgingBudget(ratio float64, maxTokens float64) HedgingOption {
+	return func(o *hedgingOptions) {
+		o.ratio = ratio
+		o.maxTokens = maxTokens
+	}
+}
+
+// WithHedgingMethods opts in the full methods, like /helloworld.Greeter/SayHello.
+func WithHedgingMethods(methods ...string) HedgingOption {
+	return func(o *hedgingOptions) {
+		for _, m := range methods {
+			o.methods[m] = struct{}{}
+		}
+	}
+}
+
+// WithHedgingProtoOption opts in the methods which set the bool method option xt to true,
+// e.g. `rpc Get(Req) returns (Reply) { option (chaos.hedging) = true; }`.
+func WithHedgingProtoOption(xt protoreflect.ExtensionType) HedgingOption {
+	return func(o *hedgingOptions) {
+		o.option = xt
+	}
+}
+
+// WithHedgingRegistry records the hedging metrics to reg instead of the default registry.
+func WithHedgingRegistry(reg metric.Registry) HedgingOption {
+	return func(o *hedgingOptions) {
+		o.registry = reg
+	}
+}
+
+type hedging struct {
+	opts   hedgingOptions
+	hedges metric.CounterVec
+
+	mu        sync.Mutex
+	tokens    float64
+	latencies map[string]*latency
+	enabled   sync.Map
+}
+
+// HedgingInterceptor sends a hedged request to another node if the response does not arrive
+// within the delay, the first success wins and the others are canceled.
+// It only picks a different node with the selector balancer.
+func HedgingInterceptor(opts ...HedgingOption) grpc.UnaryClientInterceptor {
+	h := &hedging{
+		opts: hedgingOptions{
+			delay:       100 * time.Millisecond,
+			maxAttempts: 2,
+			ratio:       0.1,
+			maxTokens:   10,
+			methods:     make(map[string]struct{}),
+			registry:    metric.DefaultRegistry(),
+		},
+		latencies: make(map[string]*latency),
+	}
+	for _, o := range opts {
+		o(&h.opts)
+	}
+	h.tokens = h.opts.maxTokens
+	h.hedges = newHedgeMetric(h.opts.registry)
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		msg, ok := reply.(proto.Message)
+		if !ok || h.opts.maxAttempts < 2 || !h.isEnabled(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		h.earn()
+		return h.invoke(ctx, method, req, msg, cc, invoker, opts...)
+	}
+}
+
+type hedgeResult struct {
+	reply   proto.Message
+	err     error
+	attempt int
+
+	header  metadata.MD
+	trailer metadata.MD
+	peer    peer.Peer
+}
+
+// callTargets are the header, trailer and peer targets of the caller's call options,
+// every attempt writes to its own targets and the returned attempt is copied to the caller's.
+type callTargets struct {
+	headers  []*metadata.MD
+	trailers []*metadata.MD
+	peers    []*peer.Peer
+}
+
+// splitCallOptions separates the targets from the other call options shared by the attempts.
+func splitCallOptions(opts []grpc.CallOption) ([]grpc.CallOption, callTargets) {
+	var (
+		shared  = make([]grpc.CallOption, 0, len(opts))
+		targets callTargets
+	)
+	for _, o := range opts {
+		switch o := o.(type) {
+		case grpc.HeaderCallOption:
+			targets.headers = append(targets.headers, o.HeaderAddr)
+		case grpc.TrailerCallOption:
+			targets.trailers = append(targets.trailers, o.TrailerAddr)
+		case grpc.PeerCallOption:
+			targets.peers = append(targets.peers, o.PeerAddr)
+		default:
+			shared = append(shared, o)
+		}
+	}
+	return shared, targets
+}
+
+func (t callTargets) set(r *hedgeResult) {
+	for _, h := range t.headers {
+		*h = r.header
+	}
+	for _, tr := range t.trailers {
+		*tr = r.trailer
+	}
+	for _, p := range t.peers {
+		*p = r.peer
+	}
+}
+
+func (h *hedging) invoke(ctx context.Context, method string, req interface{}, reply proto.Message, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	// cancel the losers
+	defer cancel()
+	ctx = selector.NewAttemptsContext(ctx, &selector.Attempts{})
+
+	shared, targets := splitCallOptions(opts)
+	results := make(chan *hedgeResult, h.opts.maxAttempts)
+	start := time.Now()
+	send := func(attempt int) {
+		r := &hedgeResult{reply: proto.Clone(reply), attempt: attempt}
+		proto.Reset(r.reply)
+		// 限制容量, append 时复制而不是并发写同一个底层数组
+		attemptOpts := append(shared[:len(shared):len(shared)],
+			grpc.Header(&r.header), grpc.Trailer(&r.trailer), grpc.Peer(&r.peer))
+		go func() {
+			r.err = invoker(ctx, method, req, r.reply, cc, attemptOpts...)
+			results <- r
+		}()
+	}
+
+	send(0)
+	sent, pending := 1, 1
+	timer := time.NewTimer(h.delay(method))
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				h.observe(method, time.Since(start))
+				if r.attempt > 0 {
+					h.hedges.Inc(method, "win")
+				}
+				proto.Reset(reply)
+				proto.Merge(reply, r.reply)
+				targets.set(r)
+				return nil
+			}
+			lastErr = r.err
+			if pending == 0 {
+				targets.set(r)
+				return lastErr
+			}
+		case <-timer.C:
+			if sent >= h.opts.maxAttempts {
+				continue
+			}
+			if !h.spend() {
+				h.hedges.Inc(method, "throttled")
+				continue
+			}
+			h.hedges.Inc(method, "sent")
+			send(sent)
+			sent++
+			pending++
+			timer.Reset(h.delay(method))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *hedging) isEnabled(method string) bool {
+	if _, ok := h.opts.methods[method]; ok {
+		return true
+	}
+	if h.opts.option == nil {
+		return false
+	}
+	if v, ok := h.enabled.Load(method); ok {
+		return v.(bool)
+	}
+	enabled := false
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1))
+	if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+		if md, ok := desc.(protoreflect.MethodDescriptor); ok && md.Options() != nil {
+			enabled, _ = proto.GetExtension(md.Options(), h.opts.option).(bool)
+		}
+	}
+	h.enabled.Store(method, enabled)
+	return enabled
+}
+
+func (h *hedging) earn() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens += h.opts.ratio
+	if h.tokens > h.opts.maxTokens {
+		h.tokens = h.opts.maxTokens
+	}
+}
+
+func (h *hedging) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+func (h *hedging) delay(method string) time.Duration {
+	if !h.opts.learnP95 {
+		return h.opts.delay
+	}
+	if p95 := h.latency(method).p95(); p95 > 0 {
+		return p95
+	}
+	return h.opts.delay
+}
+
+func (h *hedging) observe(method string, d time.Duration) {
+	if h.opts.learnP95 {
+		h.latency(method).observe(d)
+	}
+}
+
+func (h *hedging) latency(method string) *latency {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.latencies[method]
+	if !ok {
+		l = &latency{}
+		h.latencies[method] = l
+	}
+	return l
+}
+
+// latency learns the p95 of the latest latencies.
+type latency struct {
+	mu      sync.Mutex
+	samples [latencyWindow]time.Duration
+	count   int
+	value   int64
+}
+
+func (l *latency) observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples[l.count%latencyWindow] = d
+	l.count++
+	if l.count%latencyRefresh != 0 {
+		return
+	}
+	n := l.count
+	if n > latencyWindow {
+		n = latencyWindow
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, l.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	atomic.StoreInt64(&l.value, int64(sorted[n*95/100]))
+}
+
+func (l *latency) p95() time.Duration {
+	return time.Duration(atomic.LoadInt64(&l.value))
+}
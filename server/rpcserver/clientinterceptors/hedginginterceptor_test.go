@@ -0,0 +1,79 @@
+package clientinterceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/chaos-ma/chaos/core/metric"
+)
+
+// fakeInvoker replies like grpc, it writes the header, trailer and peer targets of opts,
+// the first attempt is slower than the hedging delay.
+func fakeInvoker() grpc.UnaryInvoker {
+	attempts := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		attempts <- i
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempt := <-attempts
+		name := []string{"slow", "fast"}[attempt]
+		if attempt == 0 {
+			select {
+			case <-time.After(200 * time.Millisecond):
+			case <-ctx.Done():
+			}
+		}
+		for _, o := range opts {
+			switch o := o.(type) {
+			case grpc.HeaderCallOption:
+				*o.HeaderAddr = metadata.Pairs("node", name)
+			case grpc.TrailerCallOption:
+				*o.TrailerAddr = metadata.Pairs("node", name)
+			case grpc.PeerCallOption:
+				*o.PeerAddr = peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, byte(attempt+1)), Port: 80}}
+			}
+		}
+		reply.(*wrapperspb.StringValue).Value = name
+		return nil
+	}
+}
+
+func TestHedgingCopiesTheWinnerCallOptions(t *testing.T) {
+	const method = "/test.Service/Get"
+	interceptor := HedgingInterceptor(
+		WithHedgingMethods(method),
+		WithHedgingDelay(10*time.Millisecond),
+		WithHedgingBudget(1, 10),
+		WithHedgingRegistry(metric.NewNopRegistry()),
+	)
+
+	var (
+		header, trailer metadata.MD
+		p               peer.Peer
+		reply           wrapperspb.StringValue
+	)
+	err := interceptor(context.Background(), method, &wrapperspb.StringValue{}, &reply, nil, fakeInvoker(),
+		grpc.Header(&header), grpc.Trailer(&trailer), grpc.Peer(&p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Value != "fast" {
+		t.Fatalf("reply = %q, want the hedged attempt", reply.Value)
+	}
+	if got := header.Get("node"); len(got) != 1 || got[0] != "fast" {
+		t.Errorf("header = %v, want the one of the winner", header)
+	}
+	if got := trailer.Get("node"); len(got) != 1 || got[0] != "fast" {
+		t.Errorf("trailer = %v, want the one of the winner", trailer)
+	}
+	if p.Addr == nil || p.Addr.String() != "10.0.0.2:80" {
+		t.Errorf("peer = %v, want the winner 10.0.0.2:80", p.Addr)
+	}
+}
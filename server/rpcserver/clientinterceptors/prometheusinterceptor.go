@@ -22,36 +22,49 @@ const serverNamespace = "rpc_client"
 /user 状态码 有label 主要是状态码
 */
 
-var (
-	metricServerReqDur = metric.NewHistogramVec(&metric.HistogramVecOpts{
-		Namespace: serverNamespace,
-		Subsystem: "requests",
-		Name:      "chaos_duration_ms",
-		Help:      "rpc server requests duration(ms).",
-		Labels:    []string{"method"},
-		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
-	})
-
-	metricServerReqCodeTotal = metric.NewCounterVec(&metric.CounterVecOpts{
-		Namespace: serverNamespace,
-		Subsystem: "requests",
-		Name:      "chaos_code_total",
-		Help:      "rpc server requests code count.",
-		Labels:    []string{"method", "code"},
-	})
-)
+type clientMetrics struct {
+	duration metric.HistogramVec
+	codes    metric.CounterVec
+}
 
+func newClientMetrics(reg metric.Registry) *clientMetrics {
+	return &clientMetrics{
+		duration: reg.NewHistogramVec(&metric.HistogramVecOpts{
+			Namespace: serverNamespace,
+			Subsystem: "requests",
+			Name:      "chaos_duration_ms",
+			Help:      "rpc server requests duration(ms).",
+			Labels:    []string{"method"},
+			Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		codes: reg.NewCounterVec(&metric.CounterVecOpts{
+			Namespace: serverNamespace,
+			Subsystem: "requests",
+			Name:      "chaos_code_total",
+			Help:      "rpc server requests code count.",
+			Labels:    []string{"method", "code"},
+		}),
+	}
+}
+
+// PrometheusInterceptor records the metrics to the default registry.
 func PrometheusInterceptor() grpc.UnaryClientInterceptor {
+	return PrometheusInterceptorWithRegistry(metric.DefaultRegistry())
+}
+
+// PrometheusInterceptorWithRegistry records the metrics to reg.
+func PrometheusInterceptorWithRegistry(reg metric.Registry) grpc.UnaryClientInterceptor {
+	m := newClientMetrics(reg)
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
 		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 
 		startTime := time.Now()
 		err := invoker(ctx, method, req, reply, cc, opts...)
 		//记录了耗时
-		metricServerReqDur.Observe(int64(time.Since(startTime)/time.Millisecond), method)
+		m.duration.ObserveContext(ctx, float64(time.Since(startTime))/float64(time.Millisecond), method)
 
 		//记录了状态码
-		metricServerReqCodeTotal.Inc(method, strconv.Itoa(int(status.Code(err))))
+		m.codes.Inc(method, strconv.Itoa(int(status.Code(err))))
 		return err
 	}
 }
@@ -0,0 +1,36 @@
+package clientinterceptors
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/log"
+)
+
+var requestIDKey = strings.ToLower(log.RequestIDHeader)
+
+func withRequestID(ctx context.Context) context.Context {
+	requestID, ok := log.RequestIDFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(requestIDKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIDKey, requestID)
+}
+
+// UnaryRequestIDInterceptor propagates the request id of the context as x-request-id metadata.
+func UnaryRequestIDInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(withRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamRequestIDInterceptor is the stream version of UnaryRequestIDInterceptor.
+func StreamRequestIDInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(withRequestID(ctx), desc, cc, method, opts...)
+}
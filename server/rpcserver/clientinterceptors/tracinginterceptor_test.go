@@ -0,0 +1,63 @@
+package clientinterceptors
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	trace2 "github.com/chaos-ma/chaos/core/trace"
+)
+
+func TestUnaryTracingInterceptorInjectsB3(t *testing.T) {
+	p, err := trace2.NewPropagator([]string{trace2.PropagatorB3Multi})
+	if err != nil {
+		t.Fatalf("NewPropagator: %v", err)
+	}
+	oldProvider, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample())))
+	otel.SetTextMapPropagator(p)
+	defer func() {
+		otel.SetTracerProvider(oldProvider)
+		otel.SetTextMapPropagator(oldPropagator)
+	}()
+
+	// 不会真正建立连接, 只用于 cc.Target()
+	cc, err := grpc.Dial("passthrough:///backend", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer cc.Close()
+
+	var md metadata.MD
+	var sc trace.SpanContext
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		sc = trace.SpanContextFromContext(ctx)
+		return nil
+	}
+	if err := UnaryTracingInterceptor(context.Background(), "/helloworld.Greeter/SayHello", nil, nil, cc, invoker); err != nil {
+		t.Fatalf("UnaryTracingInterceptor: %v", err)
+	}
+
+	want := map[string]string{
+		"x-b3-traceid": sc.TraceID().String(),
+		"x-b3-spanid":  sc.SpanID().String(),
+		"x-b3-sampled": "1",
+	}
+	for k, v := range want {
+		if got := md.Get(k); len(got) != 1 || got[0] != v {
+			t.Errorf("metadata %s = %v, want %s", k, got, v)
+		}
+	}
+	for _, k := range []string{"traceparent", "b3"} {
+		if got := md.Get(k); len(got) != 0 {
+			t.Errorf("metadata %s = %v, want none", k, got)
+		}
+	}
+}
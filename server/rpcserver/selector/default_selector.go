@@ -3,14 +3,19 @@ package selector
 import (
 	"context"
 	"sync/atomic"
+	"time"
 )
 
 // Default is composite selector.
 type Default struct {
 	NodeBuilder WeightedNodeBuilder
 	Balancer    Balancer
+	// Outlier ejects unhealthy nodes from candidates, nil disables it.
+	Outlier *OutlierDetector
 
 	nodes atomic.Value
+	// service 是最近一次非空节点列表的服务名, 节点全部下线时用于清理 outlier 的统计
+	service atomic.Value
 }
 
 // Select is select one node.
@@ -23,6 +28,13 @@ func (d *Default) Select(ctx context.Context) (selected Node, done DoneFunc, err
 		return nil, nil, ErrNoAvailable
 	}
 	candidates = nodes
+	if d.Outlier != nil {
+		candidates = d.Outlier.Filter(candidates)
+	}
+	attempts, hasAttempts := FromAttemptsContext(ctx)
+	if hasAttempts {
+		candidates = attempts.Filter(candidates)
+	}
 
 	if len(candidates) == 0 {
 		return nil, nil, ErrNoAvailable
@@ -31,6 +43,16 @@ func (d *Default) Select(ctx context.Context) (selected Node, done DoneFunc, err
 	if err != nil {
 		return nil, nil, err
 	}
+	if d.Outlier != nil {
+		pickDone := done
+		done = func(ctx context.Context, di DoneInfo) {
+			pickDone(ctx, di)
+			d.Outlier.Report(wn, di.Err)
+		}
+	}
+	if hasAttempts {
+		attempts.Add(wn)
+	}
 	p, ok := FromPeerContext(ctx)
 	if ok {
 		p.Node = wn.Raw()
@@ -44,14 +66,54 @@ func (d *Default) Apply(nodes []Node) {
 	for _, n := range nodes {
 		weightedNodes = append(weightedNodes, d.NodeBuilder.Build(n))
 	}
+	if len(nodes) > 0 {
+		d.service.Store(nodes[0].ServiceName())
+	}
+	if service, ok := d.service.Load().(string); ok && d.Outlier != nil {
+		d.Outlier.Update(service, nodes)
+	}
 	// TODO: Do not delete unchanged nodes
 	d.nodes.Store(weightedNodes)
 }
 
+// NodeState is the runtime state of a node for debugging.
+type NodeState struct {
+	Address      string            `json:"address"`
+	Version      string            `json:"version,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Weight       float64           `json:"weight"`
+	PickElapsed  time.Duration     `json:"pick_elapsed_ns"`
+	EjectedUntil *time.Time        `json:"ejected_until,omitempty"`
+}
+
+// Snapshot returns the state of the applied nodes.
+func (d *Default) Snapshot() []NodeState {
+	nodes, _ := d.nodes.Load().([]WeightedNode)
+	states := make([]NodeState, 0, len(nodes))
+	for _, n := range nodes {
+		st := NodeState{
+			Address:     n.Address(),
+			Version:     n.Version(),
+			Metadata:    n.Metadata(),
+			Weight:      n.Weight(),
+			PickElapsed: n.PickElapsed(),
+		}
+		if d.Outlier != nil {
+			if until := d.Outlier.EjectedUntil(n.ServiceName(), n.Address()); !until.IsZero() {
+				st.EjectedUntil = &until
+			}
+		}
+		states = append(states, st)
+	}
+	return states
+}
+
 // DefaultBuilder is de
 type DefaultBuilder struct {
 	Node     WeightedNodeBuilder
 	Balancer BalancerBuilder
+	// Outlier is shared by all built selectors, so the ejections survive the picker rebuilding.
+	Outlier *OutlierDetector
 }
 
 // Build create builder
@@ -59,5 +121,6 @@ func (db *DefaultBuilder) Build() Selector {
 	return &Default{
 		NodeBuilder: db.Node,
 		Balancer:    db.Balancer.Build(),
+		Outlier:     db.Outlier,
 	}
 }
@@ -0,0 +1,40 @@
+package selector
+
+import (
+	"strconv"
+)
+
+// Trailer keys of the server load report.
+const (
+	// LoadCPUKey is the cpu utilization of the server in permille.
+	LoadCPUKey = "x-chaos-load-cpu"
+	// LoadInflightKey is the in-flight requests of the server.
+	LoadInflightKey = "x-chaos-load-inflight"
+	// LoadQPSKey is the requests per second of the server.
+	LoadQPSKey = "x-chaos-load-qps"
+)
+
+// LoadReport is the load reported by the server in the response trailer.
+type LoadReport struct {
+	// CPU utilization in permille, 1000 means all cores are busy.
+	CPU      int64
+	Inflight int64
+	// QPS is for the dashboards and the custom balancers, the in-flight requests already
+	// reflect the traffic of the other clients so ewma doesn't weight with it.
+	QPS int64
+}
+
+// ParseLoadReport parses the load report from the reply metadata.
+func ParseLoadReport(md ReplyMD) (report LoadReport, ok bool) {
+	if md == nil {
+		return report, false
+	}
+	cpu, err := strconv.ParseInt(md.Get(LoadCPUKey), 10, 64)
+	if err != nil {
+		return report, false
+	}
+	report.CPU = cpu
+	report.Inflight, _ = strconv.ParseInt(md.Get(LoadInflightKey), 10, 64)
+	report.QPS, _ = strconv.ParseInt(md.Get(LoadQPSKey), 10, 64)
+	return report, true
+}
@@ -17,6 +17,10 @@ const (
 	tau = int64(time.Millisecond * 600)
 	// if statistic not collected,we add a big lag penalty to endpoint
 	penalty = uint64(time.Second * 10)
+	// server load report older than this is ignored
+	loadExpire = int64(time.Second * 5)
+	// the weight of a node never drops below this ratio because of the server cpu
+	minCPUFactor = 0.05
 )
 
 var (
@@ -42,6 +46,11 @@ type Node struct {
 	// last lastPick timestamp
 	lastPick int64
 
+	// server load reported in trailers
+	serverCPU      int64
+	serverInflight int64
+	loadStamp      int64
+
 	errHandler func(err error) (isErr bool)
 	lk         sync.RWMutex
 }
@@ -112,11 +121,46 @@ func (n *Node) load() (load uint64) {
 		if predict > avgLag {
 			avgLag = predict
 		}
-		load = uint64(avgLag) * uint64(atomic.LoadInt64(&n.inflight))
+		inflight := atomic.LoadInt64(&n.inflight)
+		// the server in-flight requests include the requests of other clients
+		if n.loadFresh(now) {
+			if serverInflight := atomic.LoadInt64(&n.serverInflight); serverInflight > inflight {
+				inflight = serverInflight
+			}
+		}
+		load = uint64(avgLag) * uint64(inflight)
 	}
 	return
 }
 
+func (n *Node) loadFresh(now int64) bool {
+	return now-atomic.LoadInt64(&n.loadStamp) < loadExpire
+}
+
+// cpuFactor is the ratio of idle cpu reported by the server.
+func (n *Node) cpuFactor() float64 {
+	if !n.loadFresh(time.Now().UnixNano()) {
+		return 1
+	}
+	factor := float64(1000-atomic.LoadInt64(&n.serverCPU)) / 1000
+	if factor < minCPUFactor {
+		factor = minCPUFactor
+	}
+	return factor
+}
+
+// LoadReport returns the server load used by Weight, the smoothed cpu and the in-flight requests,
+// QPS is not used.
+func (n *Node) LoadReport() (report selector2.LoadReport, ok bool) {
+	if !n.loadFresh(time.Now().UnixNano()) {
+		return report, false
+	}
+	return selector2.LoadReport{
+		CPU:      atomic.LoadInt64(&n.serverCPU),
+		Inflight: atomic.LoadInt64(&n.serverInflight),
+	}, true
+}
+
 // Pick pick a node.
 func (n *Node) Pick() selector2.DoneFunc {
 	now := time.Now().UnixNano()
@@ -167,12 +211,22 @@ func (n *Node) Pick() selector2.DoneFunc {
 		oldSuc := atomic.LoadUint64(&n.success)
 		success = uint64(float64(oldSuc)*w + float64(success)*(1.0-w))
 		atomic.StoreUint64(&n.success, success)
+
+		if report, ok := selector2.ParseLoadReport(di.ReplyMD); ok {
+			cpu := report.CPU
+			if n.loadFresh(now) {
+				cpu = int64(float64(atomic.LoadInt64(&n.serverCPU))*w + float64(cpu)*(1.0-w))
+			}
+			atomic.StoreInt64(&n.serverCPU, cpu)
+			atomic.StoreInt64(&n.serverInflight, report.Inflight)
+			atomic.StoreInt64(&n.loadStamp, now)
+		}
 	}
 }
 
 // Weight is node effective weight.
 func (n *Node) Weight() (weight float64) {
-	weight = float64(n.health()*uint64(time.Second)) / float64(n.load())
+	weight = float64(n.health()*uint64(time.Second)) / float64(n.load()) * n.cpuFactor()
 	return
 }
 
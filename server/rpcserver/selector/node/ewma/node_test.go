@@ -0,0 +1,48 @@
+package ewma
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chaos-ma/chaos/server/rpcserver/selector"
+)
+
+type replyMD map[string]string
+
+func (md replyMD) Get(key string) string {
+	return md[key]
+}
+
+func TestWeightUsesServerLoad(t *testing.T) {
+	b := &Builder{}
+	idle := b.Build(selector.NewNode("grpc", "127.0.0.1:9000", nil)).(*Node)
+	busy := b.Build(selector.NewNode("grpc", "127.0.0.1:9001", nil)).(*Node)
+
+	report := func(n *Node, cpu, inflight string) {
+		done := n.Pick()
+		done(context.Background(), selector.DoneInfo{ReplyMD: replyMD{
+			selector.LoadCPUKey:      cpu,
+			selector.LoadInflightKey: inflight,
+			selector.LoadQPSKey:      "100",
+		}})
+	}
+	report(idle, "100", "0")
+	report(busy, "100", "0")
+	// 两个节点的延迟统计相同, 只有服务端负载不同
+	busy.lag, busy.stamp = idle.lag, idle.stamp
+	if w1, w2 := idle.Weight(), busy.Weight(); w1 != w2 {
+		t.Fatalf("weights = %v, %v, want the same before the load differs", w1, w2)
+	}
+
+	// 上次统计很久以前, cpu 不再被平滑
+	busy.stamp = time.Now().Add(-time.Minute).UnixNano()
+	report(busy, "900", "8")
+	busy.lag, busy.stamp = idle.lag, idle.stamp
+	if r, ok := busy.LoadReport(); !ok || r.Inflight != 8 || r.CPU < 800 {
+		t.Fatalf("load report = %+v, %v", r, ok)
+	}
+	if w1, w2 := idle.Weight(), busy.Weight(); w2 >= w1/2 {
+		t.Errorf("weight of the busy node = %v, idle = %v, want much lower", w2, w1)
+	}
+}
@@ -0,0 +1,357 @@
+package selector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/log"
+)
+
+const outlierNamespace = "rpc_client"
+
+const (
+	reasonConsecutive = "consecutive_errors"
+	reasonRatio       = "error_ratio"
+)
+
+// OutlierOption is outlier detector option.
+type OutlierOption func(o *OutlierDetector)
+
+// WithConsecutiveErrors sets the number of consecutive errors which eject a node.
+// zero disables the consecutive errors detection.
+func WithConsecutiveErrors(n int64) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.consecutiveErrors = n
+	}
+}
+
+// WithErrorRatio sets the error ratio in one interval which ejects a node.
+// zero disables the error ratio detection.
+func WithErrorRatio(ratio float64, minRequests int64) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.errorRatio = ratio
+		o.minRequests = minRequests
+	}
+}
+
+// WithInterval sets the statistic window of the error ratio detection.
+func WithInterval(interval time.Duration) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.interval = interval
+	}
+}
+
+// WithEjectionTime sets the base and the max ejection time,
+// the real ejection time is base * 2^(ejections-1) and never exceeds max.
+func WithEjectionTime(base, max time.Duration) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.baseEjectionTime = base
+		o.maxEjectionTime = max
+	}
+}
+
+// WithMaxEjectionPercent sets the max percent of nodes which can be ejected at the same time,
+// at least one node can be ejected regardless of the value.
+func WithMaxEjectionPercent(percent int) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.maxEjectionPercent = percent
+	}
+}
+
+// WithOutlierErrHandler sets the function which decides whether err counts as a node failure.
+func WithOutlierErrHandler(h func(err error) (isErr bool)) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.errHandler = h
+	}
+}
+
+// WithOutlierRegistry records the ejections to reg, metric.DefaultRegistry() by default.
+func WithOutlierRegistry(reg metric.Registry) OutlierOption {
+	return func(o *OutlierDetector) {
+		o.registry = reg
+	}
+}
+
+// OutlierDetector ejects nodes with consecutive errors or high error ratio
+// from the selector candidates, like the envoy outlier detection.
+type OutlierDetector struct {
+	consecutiveErrors  int64
+	errorRatio         float64
+	minRequests        int64
+	interval           time.Duration
+	baseEjectionTime   time.Duration
+	maxEjectionTime    time.Duration
+	maxEjectionPercent int
+	errHandler         func(err error) (isErr bool)
+	registry           metric.Registry
+
+	ejectionsTotal metric.CounterVec
+	ejectedNodes   metric.GaugeVec
+
+	mu sync.Mutex
+	// stats 按服务分组, 一个服务的节点更新不影响其他服务
+	stats map[string]map[string]*outlierStat
+}
+
+type outlierStat struct {
+	service string
+
+	consecutive int64
+	requests    int64
+	failures    int64
+	windowStart time.Time
+
+	// ejections is the ejection multiplier, it decreases after the node stays healthy
+	ejections    int64
+	ejectedUntil time.Time
+	releasedAt   time.Time
+	// generation 标识最近一次驱逐, 只有它的定时器释放节点;
+	// counted 表示节点计入了 ejected_nodes, 每次驱逐只减一次
+	generation int64
+	counted    bool
+}
+
+// NewOutlierDetector creates an outlier detector.
+func NewOutlierDetector(opts ...OutlierOption) *OutlierDetector {
+	o := &OutlierDetector{
+		consecutiveErrors:  5,
+		errorRatio:         0.5,
+		minRequests:        10,
+		interval:           10 * time.Second,
+		baseEjectionTime:   30 * time.Second,
+		maxEjectionTime:    300 * time.Second,
+		maxEjectionPercent: 10,
+		stats:              make(map[string]map[string]*outlierStat),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.registry == nil {
+		o.registry = metric.DefaultRegistry()
+	}
+	o.ejectionsTotal = o.registry.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: outlierNamespace,
+		Subsystem: "outlier",
+		Name:      "chaos_ejections_total",
+		Help:      "rpc client outlier ejections count.",
+		Labels:    []string{"service", "address", "reason"},
+	})
+	o.ejectedNodes = o.registry.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: outlierNamespace,
+		Subsystem: "outlier",
+		Name:      "chaos_ejected_nodes",
+		Help:      "rpc client currently ejected nodes.",
+		Labels:    []string{"service"},
+	})
+	return o
+}
+
+// Filter returns the nodes which are not ejected,
+// all nodes are returned if every node is ejected.
+func (o *OutlierDetector) Filter(nodes []WeightedNode) []WeightedNode {
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ejected int
+	for _, n := range nodes {
+		if o.isEjected(n.ServiceName(), n.Address(), now) {
+			ejected++
+		}
+	}
+	if ejected == 0 || ejected == len(nodes) {
+		return nodes
+	}
+	available := make([]WeightedNode, 0, len(nodes)-ejected)
+	for _, n := range nodes {
+		if !o.isEjected(n.ServiceName(), n.Address(), now) {
+			available = append(available, n)
+		}
+	}
+	return available
+}
+
+// Report records the result of one request to the node.
+func (o *OutlierDetector) Report(n Node, err error) {
+	now := time.Now()
+	failed := o.isErr(err)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	st := o.stat(n.ServiceName(), n.Address(), now)
+	if now.Sub(st.windowStart) > o.interval {
+		st.requests, st.failures = 0, 0
+		st.windowStart = now
+	}
+	// the node has been healthy for a whole max ejection time, reduce its ejection multiplier
+	if st.ejections > 0 && !st.releasedAt.IsZero() && now.Sub(st.releasedAt) > o.maxEjectionTime {
+		st.ejections--
+		st.releasedAt = now
+	}
+
+	st.requests++
+	if !failed {
+		st.consecutive = 0
+		return
+	}
+	st.consecutive++
+	st.failures++
+
+	if now.Before(st.ejectedUntil) {
+		return
+	}
+	if o.consecutiveErrors > 0 && st.consecutive >= o.consecutiveErrors {
+		o.eject(n.Address(), st, reasonConsecutive, now)
+		return
+	}
+	if o.errorRatio > 0 && st.requests >= o.minRequests &&
+		float64(st.failures)/float64(st.requests) >= o.errorRatio {
+		o.eject(n.Address(), st, reasonRatio, now)
+	}
+}
+
+// Update tracks the current nodes of the service and drops the statistics of its removed nodes,
+// the nodes of the other services are not touched.
+func (o *OutlierDetector) Update(service string, nodes []Node) {
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	addrs := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		addrs[n.Address()] = struct{}{}
+		o.stat(service, n.Address(), now)
+	}
+	for addr, st := range o.stats[service] {
+		if _, ok := addrs[addr]; ok {
+			continue
+		}
+		o.release(st)
+		delete(o.stats[service], addr)
+	}
+	if len(o.stats[service]) == 0 {
+		delete(o.stats, service)
+	}
+}
+
+// stat returns the statistics of the node, or creates it, the caller must hold the lock.
+func (o *OutlierDetector) stat(service, addr string, now time.Time) *outlierStat {
+	nodes, ok := o.stats[service]
+	if !ok {
+		nodes = make(map[string]*outlierStat)
+		o.stats[service] = nodes
+	}
+	st, ok := nodes[addr]
+	if !ok {
+		st = &outlierStat{service: service, windowStart: now}
+		nodes[addr] = st
+	}
+	return st
+}
+
+func (o *OutlierDetector) eject(addr string, st *outlierStat, reason string, now time.Time) {
+	if !o.canEject(st.service, now) {
+		log.Warnf("[selector] skip ejecting node %s of %s(%s), max ejection percent %d%% reached",
+			addr, st.service, reason, o.maxEjectionPercent)
+		return
+	}
+
+	st.ejections++
+	ejectionTime := o.baseEjectionTime
+	for i := int64(1); i < st.ejections && ejectionTime < o.maxEjectionTime; i++ {
+		ejectionTime *= 2
+	}
+	if ejectionTime > o.maxEjectionTime {
+		ejectionTime = o.maxEjectionTime
+	}
+	st.ejectedUntil = now.Add(ejectionTime)
+	st.releasedAt = st.ejectedUntil
+	st.consecutive = 0
+	st.requests, st.failures = 0, 0
+	st.windowStart = st.ejectedUntil
+
+	o.ejectionsTotal.Inc(st.service, addr, reason)
+	// 上一次驱逐的定时器还没有释放节点时, 由这次驱逐继续计数
+	if !st.counted {
+		o.ejectedNodes.Inc(st.service)
+		st.counted = true
+	}
+	st.generation++
+	generation := st.generation
+	log.Warnf("[selector] eject node %s of %s for %s(%s), ejections: %d",
+		addr, st.service, ejectionTime, reason, st.ejections)
+
+	time.AfterFunc(ejectionTime, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if st.generation == generation && o.release(st) {
+			log.Infof("[selector] node %s of %s is back from ejection", addr, st.service)
+		}
+	})
+}
+
+// release removes the node from ejected_nodes once, the caller must hold the lock.
+func (o *OutlierDetector) release(st *outlierStat) bool {
+	if !st.counted {
+		return false
+	}
+	st.counted = false
+	o.ejectedNodes.Add(-1, st.service)
+	return true
+}
+
+// canEject checks the max ejection percent of the service, the caller must hold the lock.
+func (o *OutlierDetector) canEject(service string, now time.Time) bool {
+	var total, ejected int
+	for _, st := range o.stats[service] {
+		total++
+		if now.Before(st.ejectedUntil) {
+			ejected++
+		}
+	}
+	max := total * o.maxEjectionPercent / 100
+	if max < 1 {
+		max = 1
+	}
+	return ejected < max
+}
+
+// EjectedUntil returns when the ejection of the node of the service ends, zero if it is not ejected.
+func (o *OutlierDetector) EjectedUntil(service, addr string) time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.isEjected(service, addr, time.Now()) {
+		return time.Time{}
+	}
+	return o.stats[service][addr].ejectedUntil
+}
+
+// isEjected checks the node is ejected, the caller must hold the lock.
+func (o *OutlierDetector) isEjected(service, addr string, now time.Time) bool {
+	st, ok := o.stats[service][addr]
+	return ok && now.Before(st.ejectedUntil)
+}
+
+func (o *OutlierDetector) isErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	if o.errHandler != nil {
+		return o.errHandler(err)
+	}
+	if err == context.Canceled {
+		// canceled by the caller, not the fault of the node
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	}
+	return false
+}
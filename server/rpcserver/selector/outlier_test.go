@@ -0,0 +1,128 @@
+package selector
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/chaos-ma/chaos/core/metric"
+	"github.com/chaos-ma/chaos/registry"
+)
+
+func newTestNode(service, addr string) Node {
+	return NewNode("grpc", addr, &registry.ServiceInstance{Name: service})
+}
+
+func TestOutlierUpdateKeepsOtherServices(t *testing.T) {
+	o := NewOutlierDetector(WithConsecutiveErrors(1), WithMaxEjectionPercent(100), WithEjectionTime(time.Minute, time.Minute))
+	a := newTestNode("a", "10.0.0.1:80")
+	b1, b2 := newTestNode("b", "10.0.0.2:80"), newTestNode("b", "10.0.0.3:80")
+	o.Update("a", []Node{a})
+	o.Update("b", []Node{b1, b2})
+
+	o.Report(b1, status.Error(codes.Unavailable, "down"))
+	if o.EjectedUntil("b", b1.Address()).IsZero() {
+		t.Fatal("b1 should be ejected")
+	}
+
+	// 重建服务 a 的 picker 不影响服务 b 的驱逐
+	o.Update("a", []Node{a})
+	if o.EjectedUntil("b", b1.Address()).IsZero() {
+		t.Error("updating service a un-ejects the node of service b")
+	}
+
+	// 服务 b 的节点下线后清理它的统计
+	o.Update("b", []Node{b2})
+	if !o.EjectedUntil("b", b1.Address()).IsZero() {
+		t.Error("the removed node is still ejected")
+	}
+}
+
+func TestOutlierSameAddressDifferentServices(t *testing.T) {
+	o := NewOutlierDetector(WithConsecutiveErrors(1), WithMaxEjectionPercent(100))
+	a, b := newTestNode("a", "10.0.0.1:80"), newTestNode("b", "10.0.0.1:80")
+	o.Update("a", []Node{a})
+	o.Update("b", []Node{b})
+
+	o.Report(a, errors.New("boom"))
+	o.Report(a, status.Error(codes.Internal, "boom"))
+	if o.EjectedUntil("a", a.Address()).IsZero() {
+		t.Fatal("a should be ejected")
+	}
+	if !o.EjectedUntil("b", b.Address()).IsZero() {
+		t.Error("the node of service b shares the ejection of service a")
+	}
+}
+
+func TestOutlierMetricsRegistry(t *testing.T) {
+	reg := prom.NewRegistry()
+	o := NewOutlierDetector(WithConsecutiveErrors(1), WithMaxEjectionPercent(100),
+		WithOutlierRegistry(metric.NewRegistry(metric.WithPrometheus(reg))))
+	n1, n2 := newTestNode("a", "10.0.0.1:80"), newTestNode("a", "10.0.0.2:80")
+	o.Update("a", []Node{n1, n2})
+	o.Report(n1, status.Error(codes.Unavailable, "down"))
+
+	if n := testutil.CollectAndCount(reg, "rpc_client_outlier_chaos_ejections_total"); n != 1 {
+		t.Errorf("ejections series in the registry = %d, want 1", n)
+	}
+	if n := testutil.CollectAndCount(reg, "rpc_client_outlier_chaos_ejected_nodes"); n != 1 {
+		t.Errorf("ejected nodes series in the registry = %d, want 1", n)
+	}
+
+	o.Update("a", []Node{n2})
+	want := "# HELP rpc_client_outlier_chaos_ejected_nodes rpc client currently ejected nodes.\n" +
+		"# TYPE rpc_client_outlier_chaos_ejected_nodes gauge\n" +
+		"rpc_client_outlier_chaos_ejected_nodes{service=\"a\"} 0\n"
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "rpc_client_outlier_chaos_ejected_nodes"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutlierReejectionReleasesOnce(t *testing.T) {
+	reg := prom.NewRegistry()
+	o := NewOutlierDetector(WithConsecutiveErrors(1), WithMaxEjectionPercent(100),
+		WithEjectionTime(20*time.Millisecond, 40*time.Millisecond),
+		WithOutlierRegistry(metric.NewRegistry(metric.WithPrometheus(reg))))
+	n := newTestNode("a", "10.0.0.1:80")
+	o.Update("a", []Node{n})
+	expect := func(value string) {
+		t.Helper()
+		want := "# HELP rpc_client_outlier_chaos_ejected_nodes rpc client currently ejected nodes.\n" +
+			"# TYPE rpc_client_outlier_chaos_ejected_nodes gauge\n" +
+			"rpc_client_outlier_chaos_ejected_nodes{service=\"a\"} " + value + "\n"
+		if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "rpc_client_outlier_chaos_ejected_nodes"); err != nil {
+			t.Error(err)
+		}
+	}
+
+	o.Report(n, status.Error(codes.Unavailable, "down"))
+	expect("1")
+
+	// 第一次驱逐的定时器到期后等待锁, 节点在此期间再次被驱逐
+	o.mu.Lock()
+	time.Sleep(40 * time.Millisecond)
+	o.eject(n.Address(), o.stats["a"][n.Address()], reasonConsecutive, time.Now())
+	o.mu.Unlock()
+	expect("1")
+
+	time.Sleep(100 * time.Millisecond)
+	expect("0")
+	if !o.EjectedUntil("a", n.Address()).IsZero() {
+		t.Error("the node should be back")
+	}
+
+	// 驱逐到期但定时器还没有释放时下线, 只减一次
+	o.Report(n, status.Error(codes.Unavailable, "down"))
+	o.mu.Lock()
+	time.Sleep(60 * time.Millisecond)
+	o.mu.Unlock()
+	o.Update("a", nil)
+	time.Sleep(20 * time.Millisecond)
+	expect("0")
+}
@@ -2,6 +2,7 @@ package selector
 
 import (
 	"context"
+	"sync"
 )
 
 type peerKey struct{}
@@ -23,3 +24,53 @@ func FromPeerContext(ctx context.Context) (p *Peer, ok bool) {
 	p, ok = ctx.Value(peerKey{}).(*Peer)
 	return
 }
+
+type attemptsKey struct{}
+
+// Attempts records the nodes picked by the attempts of one call, like hedging or retry,
+// the following attempts prefer the nodes which have not been picked yet.
+type Attempts struct {
+	mu     sync.Mutex
+	picked map[string]struct{}
+}
+
+// NewAttemptsContext creates a new context with attempts attached.
+func NewAttemptsContext(ctx context.Context, a *Attempts) context.Context {
+	return context.WithValue(ctx, attemptsKey{}, a)
+}
+
+// FromAttemptsContext returns the attempts in ctx if it exists.
+func FromAttemptsContext(ctx context.Context) (a *Attempts, ok bool) {
+	a, ok = ctx.Value(attemptsKey{}).(*Attempts)
+	return
+}
+
+// Filter returns the nodes which have not been picked,
+// all nodes are returned if every node has been picked.
+func (a *Attempts) Filter(nodes []WeightedNode) []WeightedNode {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.picked) == 0 {
+		return nodes
+	}
+	filtered := make([]WeightedNode, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := a.picked[n.Address()]; !ok {
+			filtered = append(filtered, n)
+		}
+	}
+	if len(filtered) == 0 {
+		return nodes
+	}
+	return filtered
+}
+
+// Add records the picked node.
+func (a *Attempts) Add(n Node) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.picked == nil {
+		a.picked = make(map[string]struct{})
+	}
+	a.picked[n.Address()] = struct{}{}
+}
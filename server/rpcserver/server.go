@@ -8,16 +8,23 @@ import (
 	"context"
 	"net"
 	"net/url"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/chaos-ma/chaos/core/metric"
 	"github.com/chaos-ma/chaos/log"
+	"github.com/chaos-ma/chaos/server/authz"
+	healthcheck "github.com/chaos-ma/chaos/server/health"
+	"github.com/chaos-ma/chaos/server/rpcserver/resolver/discovery"
 	srvintc "github.com/chaos-ma/chaos/server/rpcserver/serverinterceptors"
+	"github.com/chaos-ma/chaos/server/tlsconfig"
 	"github.com/chaos-ma/chaos/utils/host"
 )
 
@@ -34,9 +41,21 @@ type Server struct {
 	timeout    time.Duration
 
 	health   *health.Server
+	checks   *healthcheck.Health
+	watch    sync.Once
 	endpoint *url.URL
-
-	enableMetrics bool
+	tlsOpts  *tlsconfig.Options
+	tls      *tlsconfig.Reloader
+
+	enableMetrics    bool
+	metrics          metric.Registry
+	enableLoadReport bool
+	verifier         srvintc.TokenVerifier
+	authOpts         []srvintc.AuthOption
+	authorizer       *authz.Authorizer
+
+	// err 保存创建时的错误, Start 时返回
+	err error
 }
 
 func (s *Server) Endpoint() *url.URL {
@@ -62,11 +81,37 @@ func NewServer(opts ...ServerOption) *Server {
 	unaryInts := []grpc.UnaryServerInterceptor{
 		srvintc.UnaryCrashInterceptor,
 		otelgrpc.UnaryServerInterceptor(),
+		srvintc.UnaryRequestIDInterceptor,
+		srvintc.UnaryBaggageInterceptor,
 	}
 	grpc.StatsHandler(otelgrpc.NewServerHandler())
 
 	if srv.enableMetrics {
-		unaryInts = append(unaryInts, srvintc.UnaryPrometheusInterceptor)
+		if srv.metrics == nil {
+			srv.metrics = metric.DefaultRegistry()
+		}
+		unaryInts = append(unaryInts, srvintc.NewUnaryPrometheusInterceptor(srv.metrics))
+	}
+
+	streamInts := []grpc.StreamServerInterceptor{
+		srvintc.StreamCrashInterceptor,
+		srvintc.StreamRequestIDInterceptor,
+		srvintc.StreamBaggageInterceptor,
+	}
+
+	if srv.verifier != nil {
+		unaryInts = append(unaryInts, srvintc.UnaryAuthInterceptor(srv.verifier, srv.authOpts...))
+		streamInts = append(streamInts, srvintc.StreamAuthInterceptor(srv.verifier, srv.authOpts...))
+	}
+
+	if srv.authorizer != nil {
+		unaryInts = append(unaryInts, srvintc.UnaryAuthzInterceptor(srv.authorizer))
+		streamInts = append(streamInts, srvintc.StreamAuthzInterceptor(srv.authorizer))
+	}
+
+	if srv.enableLoadReport {
+		unaryInts = append(unaryInts, srvintc.UnaryLoadReportInterceptor)
+		streamInts = append(streamInts, srvintc.StreamLoadReportInterceptor)
 	}
 
 	if srv.timeout > 0 {
@@ -77,8 +122,26 @@ func NewServer(opts ...ServerOption) *Server {
 		unaryInts = append(unaryInts, srv.unaryInts...)
 	}
 
+	if len(srv.streamInts) > 0 {
+		streamInts = append(streamInts, srv.streamInts...)
+	}
+
 	//把我们传入的拦截器转换成grpc的ServerOption
-	grpcOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unaryInts...)}
+	grpcOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInts...),
+		grpc.ChainStreamInterceptor(streamInts...),
+	}
+
+	if srv.tlsOpts != nil {
+		r, err := tlsconfig.NewReloader(srv.tlsOpts)
+		if err != nil {
+			log.Errorf("[grpc] failed to load tls config: %s", err)
+			srv.err = err
+		} else {
+			srv.tls = r
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(r.ServerConfig())))
+		}
+	}
 
 	//把用户自己传入的grpc.ServerOption放在一起
 	if len(srv.grpcOpts) > 0 {
@@ -113,6 +176,43 @@ func WithMetrics(metric bool) ServerOption {
 	}
 }
 
+// WithMetricRegistry records the metrics to reg instead of the default registry.
+func WithMetricRegistry(reg metric.Registry) ServerOption {
+	return func(s *Server) {
+		s.metrics = reg
+	}
+}
+
+// WithLoadReport reports the server load in the response trailers for the client balancers.
+func WithLoadReport(enable bool) ServerOption {
+	return func(s *Server) {
+		s.enableLoadReport = enable
+	}
+}
+
+// WithAuth authenticates the bearer token of every rpc with the verifier, like auth.CacheStrategy.
+func WithAuth(v srvintc.TokenVerifier, opts ...srvintc.AuthOption) ServerOption {
+	return func(s *Server) {
+		s.verifier = v
+		s.authOpts = opts
+	}
+}
+
+// WithAuthorizer authorizes every rpc after the authentication of WithAuth.
+func WithAuthorizer(a *authz.Authorizer) ServerOption {
+	return func(s *Server) {
+		s.authorizer = a
+	}
+}
+
+// WithHealth drives the grpc health status by the checks of h, the services bound
+// to the failed critical checks are NOT_SERVING.
+func WithHealth(h *healthcheck.Health) ServerOption {
+	return func(s *Server) {
+		s.checks = h
+	}
+}
+
 func WithTimeout(timeout time.Duration) ServerOption {
 	return func(s *Server) {
 		s.timeout = timeout
@@ -137,6 +237,13 @@ func WithStreamInterceptor(in ...grpc.StreamServerInterceptor) ServerOption {
 	}
 }
 
+// WithTLSConfig serves grpc over tls, and the endpoint is registered with isSecure=true.
+func WithTLSConfig(opts *tlsconfig.Options) ServerOption {
+	return func(s *Server) {
+		s.tlsOpts = opts
+	}
+}
+
 func WithOptions(opts ...grpc.ServerOption) ServerOption {
 	return func(s *Server) {
 		s.grpcOpts = opts
@@ -157,21 +264,52 @@ func (s *Server) listenAndEndpoint() error {
 		_ = s.lis.Close()
 		return err
 	}
-	s.endpoint = &url.URL{Scheme: "grpc", Host: addr}
+	s.endpoint = discovery.NewEndpoint("grpc", addr, s.tlsOpts != nil)
 	return nil
 }
 
 // Start 启动grpc的服务
 func (s *Server) Start(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
 	log.Infof("[grpc] server listening on: %s", s.lis.Addr().String())
 	s.health.Resume()
+	if s.checks != nil {
+		s.watch.Do(func() {
+			s.checks.Watch(s.applyHealth)
+		})
+		s.checks.Resume()
+	}
 	return s.Serve(s.lis)
 }
 
+// applyHealth sets the status of every registered service, the overall status is the empty service.
+func (s *Server) applyHealth(r healthcheck.Report) {
+	overall := grpc_health_v1.HealthCheckResponse_SERVING
+	if r.Status == healthcheck.StatusDown {
+		overall = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	s.health.SetServingStatus("", overall)
+	for name := range s.GetServiceInfo() {
+		status := grpc_health_v1.HealthCheckResponse_SERVING
+		if r.ServiceStatus(name) == healthcheck.StatusDown {
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+		s.health.SetServingStatus(name, status)
+	}
+}
+
 func (s *Server) Stop(ctx context.Context) error {
 	//设置服务的状态为not_serving，防止接收新的请求过来
+	if s.checks != nil {
+		s.checks.Shutdown()
+	}
 	s.health.Shutdown()
 	s.GracefulStop()
+	if s.tls != nil {
+		s.tls.Stop()
+	}
 	log.Infof("[grpc] server stopped")
 	return nil
 }
@@ -0,0 +1,129 @@
+package serverinterceptors
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/chaos-ma/chaos/log"
+)
+
+const (
+	authorizationKey = "authorization"
+	bearerScheme     = "Bearer"
+)
+
+// TokenVerifier verifies the bearer token and returns the identity of the caller,
+// auth.CacheStrategy and auth.JWTStrategy implement it.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (username string, err error)
+}
+
+// NewUsernameContext creates a new context with the authenticated username attached,
+// it is log.NewUsernameContext so the username is shared with the http side.
+func NewUsernameContext(ctx context.Context, username string) context.Context {
+	return log.NewUsernameContext(ctx, username)
+}
+
+// UsernameFromContext returns the authenticated username in ctx if it exists, see log.UsernameFromContext.
+func UsernameFromContext(ctx context.Context) (username string, ok bool) {
+	return log.UsernameFromContext(ctx)
+}
+
+// AuthOption is auth interceptor option.
+type AuthOption func(o *authOptions)
+
+type authOptions struct {
+	skip func(fullMethod string) bool
+}
+
+// WithAuthSkip skips the authentication of the methods which skip returns true,
+// the health check and reflection methods are always skipped.
+func WithAuthSkip(skip func(fullMethod string) bool) AuthOption {
+	return func(o *authOptions) {
+		o.skip = skip
+	}
+}
+
+type authenticator struct {
+	verifier TokenVerifier
+	opts     authOptions
+}
+
+func newAuthenticator(v TokenVerifier, opts ...AuthOption) *authenticator {
+	a := &authenticator{verifier: v}
+	for _, o := range opts {
+		o(&a.opts)
+	}
+	return a
+}
+
+// builtinMethod reports whether fullMethod is a health check or reflection method, they skip the auth and authz.
+func builtinMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.") || strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+func (a *authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if builtinMethod(fullMethod) {
+		return ctx, nil
+	}
+	if a.opts.skip != nil && a.opts.skip(fullMethod) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata cannot be empty")
+	}
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || parts[0] != bearerScheme {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata format is wrong")
+	}
+
+	username, err := a.verifier.VerifyToken(ctx, parts[1])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return NewUsernameContext(ctx, username), nil
+}
+
+// UnaryAuthInterceptor authenticates the bearer token in the authorization metadata,
+// and puts the username into the context.
+func UnaryAuthInterceptor(v TokenVerifier, opts ...AuthOption) grpc.UnaryServerInterceptor {
+	a := newAuthenticator(v, opts...)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor is the stream version of UnaryAuthInterceptor.
+func StreamAuthInterceptor(v TokenVerifier, opts ...AuthOption) grpc.StreamServerInterceptor {
+	a := newAuthenticator(v, opts...)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,83 @@
+package serverinterceptors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/chaos-ma/chaos/log"
+)
+
+type staticVerifier map[string]string
+
+func (v staticVerifier) VerifyToken(_ context.Context, token string) (string, error) {
+	username, ok := v[token]
+	if !ok {
+		return "", errors.New("token is invalid")
+	}
+	return username, nil
+}
+
+func TestUnaryAuthInterceptor(t *testing.T) {
+	conn := dialBufconn(t, []grpc.UnaryServerInterceptor{
+		UnaryAuthInterceptor(staticVerifier{"good": "alice"}),
+	})
+	client := testpb.NewTestServiceClient(conn)
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          codes.Code
+	}{
+		{"missing", "", codes.Unauthenticated},
+		{"wrong scheme", "Basic good", codes.Unauthenticated},
+		{"no token", "Bearer", codes.Unauthenticated},
+		{"bad token", "Bearer bad", codes.Unauthenticated},
+		{"good token", "Bearer good", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+			var header metadata.MD
+			_, err := client.EmptyCall(ctx, &testpb.Empty{}, grpc.Header(&header))
+			if status.Code(err) != tt.code {
+				t.Fatalf("err = %v, want %s", err, tt.code)
+			}
+			if tt.code == codes.OK {
+				if got := header.Get("x-username"); len(got) != 1 || got[0] != "alice" {
+					t.Errorf("username = %v, want alice", got)
+				}
+			}
+		})
+	}
+
+	// 健康检查不需要认证
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("health check: %v", err)
+	}
+}
+
+func TestUsernameSharedWithHTTP(t *testing.T) {
+	ctx := NewUsernameContext(context.Background(), "alice")
+	if username, ok := log.UsernameFromContext(ctx); !ok || username != "alice" {
+		t.Errorf("log.UsernameFromContext = %q, %v", username, ok)
+	}
+
+	// http 的认证中间件把用户名放在 gin 的 key 中
+	c := &gin.Context{}
+	c.Set(log.KeyUsername, "bob")
+	if username, ok := UsernameFromContext(c); !ok || username != "bob" {
+		t.Errorf("UsernameFromContext(gin) = %q, %v", username, ok)
+	}
+}
@@ -0,0 +1,54 @@
+package serverinterceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/chaos-ma/chaos/server/authz"
+)
+
+func authorize(ctx context.Context, a *authz.Authorizer, fullMethod string) error {
+	if builtinMethod(fullMethod) {
+		return nil
+	}
+	username, _ := UsernameFromContext(ctx)
+	req := authz.Request{
+		Subject:  username,
+		Resource: fullMethod,
+		Action:   authz.ActionRPC,
+	}
+	allowed, err := a.Authorize(ctx, req)
+	if err != nil {
+		return status.Errorf(codes.PermissionDenied, "authorization failed: %s", err)
+	}
+	if !allowed {
+		return status.Errorf(codes.PermissionDenied, "%s is not allowed to call %s", username, fullMethod)
+	}
+	return nil
+}
+
+// UnaryAuthzInterceptor authorizes the authenticated user on the full method,
+// it must be chained after UnaryAuthInterceptor.
+func UnaryAuthzInterceptor(a *authz.Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, a, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthzInterceptor is the stream version of UnaryAuthzInterceptor.
+func StreamAuthzInterceptor(a *authz.Authorizer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), a, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
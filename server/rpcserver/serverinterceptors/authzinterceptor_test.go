@@ -0,0 +1,97 @@
+package serverinterceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/chaos-ma/chaos/server/authz"
+)
+
+// testService returns the authenticated username in the header.
+type testService struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (testService) EmptyCall(ctx context.Context, _ *testpb.Empty) (*testpb.Empty, error) {
+	username, _ := UsernameFromContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-username", username))
+	return &testpb.Empty{}, nil
+}
+
+// dialBufconn serves the test and health services with the interceptors over bufconn.
+func dialBufconn(t *testing.T, unary []grpc.UnaryServerInterceptor, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(unary...))
+	testpb.RegisterTestServiceServer(srv, testService{})
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+	conn, err := grpc.Dial("passthrough:///bufconn", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// usernameFromMetadata stands for the auth interceptor in the authz tests.
+func usernameFromMetadata(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-user"); len(v) > 0 {
+		ctx = NewUsernameContext(ctx, v[0])
+	}
+	return handler(ctx, req)
+}
+
+func TestUnaryAuthzInterceptor(t *testing.T) {
+	p, err := authz.NewPolicy([]authz.Role{
+		{Name: "caller", Rules: []authz.Rule{{Resources: []string{"/grpc.testing.TestService/*"}, Actions: []string{authz.ActionRPC}}}},
+	}, map[string][]string{"alice": {"caller"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := authz.NewAuthorizer(authz.WithPolicy(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dialBufconn(t, []grpc.UnaryServerInterceptor{usernameFromMetadata, UnaryAuthzInterceptor(a)})
+	client := testpb.NewTestServiceClient(conn)
+
+	call := func(user string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-user", user)
+		_, err := client.EmptyCall(ctx, &testpb.Empty{})
+		return err
+	}
+	if err := call("alice"); err != nil {
+		t.Fatalf("alice: %v", err)
+	}
+	if err := call("bob"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("bob: %v, want PermissionDenied", err)
+	}
+
+	// 健康检查不需要授权
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health = %s", resp.GetStatus())
+	}
+}
@@ -0,0 +1,36 @@
+package serverinterceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/core/baggage"
+)
+
+func baggageContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return baggage.ExtractMetadata(ctx, md)
+}
+
+// UnaryBaggageInterceptor extracts the allowed members of the baggage metadata into the context.
+func UnaryBaggageInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(baggageContext(ctx), req)
+}
+
+// StreamBaggageInterceptor is the stream version of UnaryBaggageInterceptor.
+func StreamBaggageInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &baggageServerStream{ServerStream: ss, ctx: baggageContext(ss.Context())})
+}
+
+type baggageServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *baggageServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,91 @@
+package serverinterceptors
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/core/baggage"
+	"github.com/chaos-ma/chaos/server/rpcserver/clientinterceptors"
+)
+
+func TestBaggageInterceptors(t *testing.T) {
+	old := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	defer otel.SetTextMapPropagator(old)
+
+	var got context.Context
+	var header []string
+	capture := func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		got = ctx
+		md, _ := metadata.FromIncomingContext(ctx)
+		header = md.Get("baggage")
+		return handler(ctx, req)
+	}
+	conn := dialBufconn(t, []grpc.UnaryServerInterceptor{UnaryBaggageInterceptor, capture},
+		// tracing 拦截器注入了未过滤的 baggage, baggage 拦截器在最后替换它
+		grpc.WithChainUnaryInterceptor(clientinterceptors.UnaryTracingInterceptor, clientinterceptors.UnaryBaggageInterceptor))
+
+	ctx := baggage.WithCanary(baggage.WithUser(baggage.WithTenant(context.Background(), "acme; inc"), "alice"), true)
+	ctx, err := baggage.With(ctx, "session", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testpb.NewTestServiceClient(conn).EmptyCall(ctx, &testpb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if baggage.Tenant(got) != "acme; inc" || baggage.User(got) != "alice" || !baggage.Canary(got) {
+		t.Errorf("tenant = %q, user = %q, canary = %v", baggage.Tenant(got), baggage.User(got), baggage.Canary(got))
+	}
+	if baggage.Get(got, "session") != "" {
+		t.Error("session should not be propagated")
+	}
+	if len(header) != 1 || strings.Contains(header[0], "session") {
+		t.Errorf("baggage metadata = %v, want one filtered header", header)
+	}
+}
+
+func TestStreamBaggageInterceptor(t *testing.T) {
+	md := metadata.Pairs("baggage", "tenant_id=t1,session=secret")
+	ss := &contextStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+	err := StreamBaggageInterceptor(nil, ss, &grpc.StreamServerInfo{}, func(_ interface{}, s grpc.ServerStream) error {
+		if baggage.Tenant(s.Context()) != "t1" || baggage.Get(s.Context(), "session") != "" {
+			t.Errorf("tenant = %q, session = %q", baggage.Tenant(s.Context()), baggage.Get(s.Context(), "session"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 客户端的 stream 同样替换 baggage
+	ctx := metadata.NewOutgoingContext(baggage.WithTenant(context.Background(), "t2"), metadata.Pairs("baggage", "session=secret"))
+	_, err = clientinterceptors.StreamBaggageInterceptor(ctx, &grpc.StreamDesc{}, nil, "/test",
+		func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if v := md.Get("baggage"); len(v) != 1 || v[0] != "tenant_id=t2" {
+				t.Errorf("outgoing baggage = %v, want tenant_id=t2", v)
+			}
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// contextStream is a server stream of ctx.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package serverinterceptors
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuTime returns the user and system cpu time used by this process.
+func cpuTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
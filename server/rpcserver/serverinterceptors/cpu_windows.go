@@ -0,0 +1,12 @@
+//go:build windows
+
+package serverinterceptors
+
+import (
+	"time"
+)
+
+// cpuTime is not supported on windows, the cpu utilization is always reported as 0.
+func cpuTime() time.Duration {
+	return 0
+}
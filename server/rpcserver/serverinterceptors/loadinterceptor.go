@@ -0,0 +1,99 @@
+package serverinterceptors
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/server/rpcserver/selector"
+)
+
+const loadSampleInterval = time.Second
+
+// serverLoad collects the load of this process, it is shared by all servers.
+type serverLoad struct {
+	once sync.Once
+
+	inflight int64
+	reqs     int64
+	qps      int64
+	cpu      int64
+}
+
+var load = &serverLoad{}
+
+func (l *serverLoad) start() {
+	l.once.Do(func() {
+		go func() {
+			lastCPU := cpuTime()
+			lastTs := time.Now()
+			ticker := time.NewTicker(loadSampleInterval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				reqs := atomic.SwapInt64(&l.reqs, 0)
+				elapsed := now.Sub(lastTs)
+				atomic.StoreInt64(&l.qps, int64(float64(reqs)/elapsed.Seconds()))
+
+				cur := cpuTime()
+				atomic.StoreInt64(&l.cpu, cpuUsage(cur-lastCPU, elapsed))
+				lastCPU, lastTs = cur, now
+			}
+		}()
+	})
+}
+
+// UnaryLoadReportInterceptor reports the cpu utilization, in-flight requests and qps
+// of the server in the response trailer, the client balancer weights nodes with them.
+func UnaryLoadReportInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	load.begin()
+	resp, err = handler(ctx, req)
+	_ = grpc.SetTrailer(ctx, load.end())
+	return resp, err
+}
+
+// StreamLoadReportInterceptor is the stream version of UnaryLoadReportInterceptor, a stream
+// is in flight until the handler returns.
+func StreamLoadReportInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	load.begin()
+	err := handler(srv, ss)
+	ss.SetTrailer(load.end())
+	return err
+}
+
+func (l *serverLoad) begin() {
+	l.start()
+	atomic.AddInt64(&l.reqs, 1)
+	atomic.AddInt64(&l.inflight, 1)
+}
+
+// end returns the load report of the finished request.
+func (l *serverLoad) end() metadata.MD {
+	inflight := atomic.AddInt64(&l.inflight, -1)
+	return metadata.Pairs(
+		selector.LoadCPUKey, strconv.FormatInt(atomic.LoadInt64(&l.cpu), 10),
+		selector.LoadInflightKey, strconv.FormatInt(inflight, 10),
+		selector.LoadQPSKey, strconv.FormatInt(atomic.LoadInt64(&l.qps), 10),
+	)
+}
+
+// cpuUsage converts the cpu time used in elapsed into permille of all cores.
+func cpuUsage(used, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	usage := int64(float64(used) / float64(elapsed) / float64(runtime.NumCPU()) * 1000)
+	if usage < 0 {
+		usage = 0
+	} else if usage > 1000 {
+		usage = 1000
+	}
+	return usage
+}
@@ -0,0 +1,61 @@
+package serverinterceptors
+
+import (
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/server/rpcserver/selector"
+)
+
+// trailerStream keeps the trailer set by the interceptor.
+type trailerStream struct {
+	grpc.ServerStream
+	trailer metadata.MD
+}
+
+func (s *trailerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func TestStreamLoadReportInterceptor(t *testing.T) {
+	outer, inner := &trailerStream{}, &trailerStream{}
+	err := StreamLoadReportInterceptor(nil, outer, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+		// 外层的 stream 在内层结束时仍在进行中
+		return StreamLoadReportInterceptor(nil, inner, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error {
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inflight := func(s *trailerStream) int64 {
+		t.Helper()
+		report, ok := selector.ParseLoadReport(trailerMD(s.trailer))
+		if !ok {
+			t.Fatalf("no load report in the trailer %v", s.trailer)
+		}
+		return report.Inflight
+	}
+	if o, i := inflight(outer), inflight(inner); i != o+1 {
+		t.Errorf("in-flight of the inner stream = %d, outer = %d, want the outer stream counted", i, o)
+	}
+	if v := outer.trailer.Get(selector.LoadQPSKey); len(v) != 1 {
+		t.Errorf("qps = %v, want reported", v)
+	} else if _, err := strconv.ParseInt(v[0], 10, 64); err != nil {
+		t.Error(err)
+	}
+}
+
+// trailerMD implements selector.ReplyMD.
+type trailerMD metadata.MD
+
+func (md trailerMD) Get(key string) string {
+	if v := metadata.MD(md).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
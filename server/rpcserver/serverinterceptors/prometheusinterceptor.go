@@ -3,6 +3,7 @@ package serverinterceptors
 import (
 	"context"
 	"strconv"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
@@ -18,35 +19,59 @@ const serverNamespace = "rpc_server"
 /user 状态码 有label 主要是状态码
 */
 
-var (
-	metricServerReqDur = metric.NewHistogramVec(&metric.HistogramVecOpts{
-		Namespace: serverNamespace,
-		Subsystem: "requests",
-		Name:      "chaos_duration_ms",
-		Help:      "rpc server requests duration(ms).",
-		Labels:    []string{"method"},
-		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
-	})
+type serverMetrics struct {
+	duration metric.HistogramVec
+	codes    metric.CounterVec
+}
 
-	metricServerReqCodeTotal = metric.NewCounterVec(&metric.CounterVecOpts{
-		Namespace: serverNamespace,
-		Subsystem: "requests",
-		Name:      "chaos_code_total",
-		Help:      "rpc server requests code count.",
-		Labels:    []string{"method", "code"},
-	})
+func newServerMetrics(reg metric.Registry) *serverMetrics {
+	return &serverMetrics{
+		duration: reg.NewHistogramVec(&metric.HistogramVecOpts{
+			Namespace: serverNamespace,
+			Subsystem: "requests",
+			Name:      "chaos_duration_ms",
+			Help:      "rpc server requests duration(ms).",
+			Labels:    []string{"method"},
+			Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+		codes: reg.NewCounterVec(&metric.CounterVecOpts{
+			Namespace: serverNamespace,
+			Subsystem: "requests",
+			Name:      "chaos_code_total",
+			Help:      "rpc server requests code count.",
+			Labels:    []string{"method", "code"},
+		}),
+	}
+}
+
+var (
+	defaultOnce       sync.Once
+	defaultPrometheus grpc.UnaryServerInterceptor
 )
 
+// UnaryPrometheusInterceptor records the metrics to the default registry, the metrics are registered on the first call.
 func UnaryPrometheusInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defaultOnce.Do(func() {
+		defaultPrometheus = NewUnaryPrometheusInterceptor(metric.DefaultRegistry())
+	})
+	return defaultPrometheus(ctx, req, info, handler)
+}
+
+// NewUnaryPrometheusInterceptor records the metrics to reg.
+func NewUnaryPrometheusInterceptor(reg metric.Registry) grpc.UnaryServerInterceptor {
+	m := newServerMetrics(reg)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp interface{}, err error) {
 
-	startTime := time.Now()
-	resp, err = handler(ctx, req)
+		startTime := time.Now()
+		resp, err = handler(ctx, req)
 
-	//记录了耗时
-	metricServerReqDur.Observe(int64(time.Since(startTime)/time.Millisecond), info.FullMethod)
+		//记录了耗时
+		m.duration.ObserveContext(ctx, float64(time.Since(startTime))/float64(time.Millisecond), info.FullMethod)
 
-	//记录了状态码
-	metricServerReqCodeTotal.Inc(info.FullMethod, strconv.Itoa(int(status.Code(err))))
-	return resp, err
+		//记录了状态码
+		m.codes.Inc(info.FullMethod, strconv.Itoa(int(status.Code(err))))
+		return resp, err
+	}
 }
@@ -0,0 +1,51 @@
+package serverinterceptors
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/log"
+)
+
+var requestIDKey = strings.ToLower(log.RequestIDHeader)
+
+// requestIDContext extracts the request id from the incoming metadata or generates one,
+// and echoes it in the response header.
+func requestIDContext(ctx context.Context) context.Context {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) > 0 && log.ValidRequestID(values[0]) {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+	return log.NewRequestIDContext(ctx, requestID)
+}
+
+// UnaryRequestIDInterceptor puts the x-request-id of the metadata into the context.
+func UnaryRequestIDInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(requestIDContext(ctx), req)
+}
+
+// StreamRequestIDInterceptor is the stream version of UnaryRequestIDInterceptor.
+func StreamRequestIDInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &requestIDServerStream{ServerStream: ss, ctx: requestIDContext(ss.Context())})
+}
+
+type requestIDServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,44 @@
+package serverinterceptors
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/chaos-ma/chaos/log"
+)
+
+func TestUnaryRequestIDInterceptorValidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"kept", "req-1", true},
+		{"control characters", "req\nlevel=error", false},
+		{"space", "req 1", false},
+		{"too long", string(make([]byte, 129)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDKey, tt.incoming))
+			var got string
+			_, err := UnaryRequestIDInterceptor(ctx, nil, &grpc.UnaryServerInfo{},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					got, _ = log.RequestIDFromContext(ctx)
+					return nil, nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == "" {
+				t.Fatal("no request id in the context")
+			}
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("request id = %q, incoming %q, keep = %v", got, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+// Options is the tls config of servers and clients, the files are reloaded when they change.
+type Options struct {
+	// CertFile and KeyFile is the certificate of this side
+	CertFile string `json:"cert-file"       mapstructure:"cert-file"`
+	KeyFile  string `json:"key-file"        mapstructure:"key-file"`
+	// CAFile verifies the client certificates on server side, and the server certificate on client side
+	CAFile string `json:"ca-file"         mapstructure:"ca-file"`
+	// ClientAuth requires and verifies the client certificate by CAFile, which is mTLS
+	ClientAuth bool `json:"client-auth"     mapstructure:"client-auth"`
+	// ServerName is used to verify the hostname of the server certificate on client side
+	ServerName string `json:"server-name"     mapstructure:"server-name"`
+	// ReloadInterval is the interval to check the changes of files, zero disables the reloading
+	ReloadInterval time.Duration `json:"reload-interval" mapstructure:"reload-interval"`
+}
+
+// NewOptions creates options with the default reload interval.
+func NewOptions() *Options {
+	return &Options{
+		ReloadInterval: 10 * time.Second,
+	}
+}
+
+// Reloader loads the certificate and the CA pool, and reloads them when the files change.
+type Reloader struct {
+	opts *Options
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes map[string]time.Time
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewReloader loads the files and starts watching them.
+func NewReloader(opts *Options) (*Reloader, error) {
+	if opts == nil {
+		return nil, errors.New("tls options is nil")
+	}
+	r := &Reloader{
+		opts:     opts,
+		modTimes: make(map[string]time.Time),
+		done:     make(chan struct{}),
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if opts.ReloadInterval > 0 {
+		go r.watch()
+	}
+	return r, nil
+}
+
+// ServerConfig returns the server side tls config, the reloaded files take effect on new connections.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			if r.cert == nil {
+				return nil, errors.New("tls certificate is not loaded")
+			}
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientCAs:    r.pool,
+				// the returned config replaces the base one, so the alpn of grpc and http2 is set again
+				NextProtos: []string{"h2", "http/1.1"},
+			}
+			if r.opts.ClientAuth {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			} else if r.pool != nil {
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns the client side tls config with the current CA pool, the client certificate is reloaded
+// on new connections but the CA pool is not, use ClientCredentials or DialTLSContext to follow the CA rotation.
+func (r *Reloader) ClientConfig() *tls.Config {
+	r.mu.RLock()
+	pool := r.pool
+	r.mu.RUnlock()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+		ServerName: r.opts.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			if r.cert == nil {
+				// no certificate is sent
+				return &tls.Certificate{}, nil
+			}
+			return r.cert, nil
+		},
+	}
+}
+
+// ClientCredentials returns the grpc client credentials, every handshake verifies the server with the current CA pool.
+func (r *Reloader) ClientCredentials() credentials.TransportCredentials {
+	return &clientCredentials{TransportCredentials: credentials.NewTLS(r.ClientConfig()), r: r}
+}
+
+// DialTLSContext returns the DialTLSContext of http.Transport which dials by dial and verifies the server with
+// the current CA pool, base provides the alpn protocols, e.g. http.Transport.TLSClientConfig.
+func (r *Reloader) DialTLSContext(base *tls.Config, dial func(ctx context.Context, network, addr string) (net.Conn, error),
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := r.ClientConfig()
+		if base != nil {
+			cfg.NextProtos = base.NextProtos
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+}
+
+// clientCredentials creates the tls credentials with the current CA pool for every handshake,
+// the server name and alpn are handled by grpc as usual.
+type clientCredentials struct {
+	credentials.TransportCredentials
+	r *Reloader
+}
+
+func (c *clientCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(c.r.ClientConfig()).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *clientCredentials) Clone() credentials.TransportCredentials {
+	return &clientCredentials{TransportCredentials: c.TransportCredentials.Clone(), r: c.r}
+}
+
+// Stop stops watching the files.
+func (r *Reloader) Stop() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+func (r *Reloader) watch() {
+	ticker := time.NewTicker(r.opts.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				log.Errorf("[tls] failed to reload certificates, keep the old ones: %s", err)
+				continue
+			}
+			log.Infof("[tls] certificates reloaded")
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.CAFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if !fi.ModTime().Equal(r.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) load() error {
+	// 没有 CA 时 ClientCAs 为空, 会用系统根证书验证客户端证书
+	if r.opts.ClientAuth && r.opts.CAFile == "" {
+		return errors.New("tls client auth requires the ca file")
+	}
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = fi.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.opts.CertFile != "" || r.opts.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+		if err != nil {
+			return errors.Wrap(err, "load x509 key pair")
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.opts.CAFile != "" {
+		ca, err := os.ReadFile(r.opts.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return errors.Errorf("no certificate found in ca file: %s", r.opts.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.pool, r.modTimes = cert, pool, modTimes
+	r.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,205 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue issues a server certificate for 127.0.0.1.
+func (ca *testCA) issue(t *testing.T) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// rotatingServer serves the current certificate on the returned address, which is replaced by the test.
+func rotatingServer(t *testing.T, cert *tls.Certificate) (string, *atomic.Value) {
+	t.Helper()
+	var current atomic.Value
+	current.Store(cert)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return current.Load().(*tls.Certificate), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler:  http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Close() })
+	return lis.Addr().String(), &current
+}
+
+func TestClientFollowsCARotation(t *testing.T) {
+	oldCA, newCA := newTestCA(t, "old"), newTestCA(t, "new")
+	addr, current := rotatingServer(t, oldCA.issue(t))
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, oldCA.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(&Options{CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	transport := &http.Transport{DialTLSContext: r.DialTLSContext(nil, (&net.Dialer{}).DialContext)}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+	get := func() error {
+		transport.CloseIdleConnections()
+		resp, err := client.Get("https://" + addr)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	creds := r.ClientCredentials()
+	handshake := func() error {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, _, err = creds.ClientHandshake(context.Background(), addr, conn)
+		return err
+	}
+
+	if err := get(); err != nil {
+		t.Fatalf("http with the old CA: %v", err)
+	}
+	if err := handshake(); err != nil {
+		t.Fatalf("grpc handshake with the old CA: %v", err)
+	}
+
+	// 服务端先换证书, 客户端还没有新 CA
+	current.Store(newCA.issue(t))
+	if err := get(); err == nil {
+		t.Fatal("http should fail before the CA is reloaded")
+	}
+	if err := handshake(); err == nil {
+		t.Fatal("grpc handshake should fail before the CA is reloaded")
+	}
+
+	if err := os.WriteFile(caFile, newCA.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(); err != nil {
+		t.Fatalf("http with the reloaded CA: %v", err)
+	}
+	if err := handshake(); err != nil {
+		t.Fatalf("grpc handshake with the reloaded CA: %v", err)
+	}
+}
+
+func TestClientAuthRequiresCAFile(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	cert := ca.issue(t)
+	dir := t.TempDir()
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"cert.pem": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}),
+		"key.pem":  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		"ca.pem":   ca.pem,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &Options{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem"), ClientAuth: true}
+	// 没有 CA 时会接受任何公开签发的客户端证书
+	if _, err := NewReloader(opts); err == nil {
+		t.Fatal("client auth without the ca file should fail")
+	}
+
+	opts.CAFile = filepath.Join(dir, "ca.pem")
+	r, err := NewReloader(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	cfg, err := r.ServerConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+		t.Errorf("client auth = %v, client CAs = %v, want verified by the CA", cfg.ClientAuth, cfg.ClientCAs)
+	}
+}
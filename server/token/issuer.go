@@ -0,0 +1,251 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// Defined errors.
+var (
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenInvalid     = errors.New("token is invalid")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrRefreshExpired   = errors.New("token is too old to refresh")
+)
+
+// Claims is the claims signed by the Issuer, custom claims embed StandardClaims.
+type Claims interface {
+	jwt.Claims
+	Registered() *jwt.RegisteredClaims
+}
+
+// StandardClaims is the registered claims, embed it into custom claims.
+type StandardClaims struct {
+	jwt.RegisteredClaims
+}
+
+// Registered returns the registered claims.
+func (c *StandardClaims) Registered() *jwt.RegisteredClaims {
+	return &c.RegisteredClaims
+}
+
+// IssuerOption is issuer option.
+type IssuerOption func(i *Issuer)
+
+// WithIssuer sets the iss claim, and requires it when parsing.
+func WithIssuer(issuer string) IssuerOption {
+	return func(i *Issuer) {
+		i.issuer = issuer
+	}
+}
+
+// WithAudience sets the aud claim, and requires it when parsing.
+func WithAudience(audience ...string) IssuerOption {
+	return func(i *Issuer) {
+		i.audience = audience
+	}
+}
+
+// WithTTL sets the lifetime of the issued tokens.
+func WithTTL(ttl time.Duration) IssuerOption {
+	return func(i *Issuer) {
+		i.ttl = ttl
+	}
+}
+
+// WithMaxRefresh sets how long after issued a token can be refreshed.
+func WithMaxRefresh(maxRefresh time.Duration) IssuerOption {
+	return func(i *Issuer) {
+		i.maxRefresh = maxRefresh
+	}
+}
+
+// WithLeeway tolerates the clock skew when validating exp and nbf.
+func WithLeeway(leeway time.Duration) IssuerOption {
+	return func(i *Issuer) {
+		i.leeway = leeway
+	}
+}
+
+// WithRevoker rejects the revoked tokens when parsing or refreshing.
+func WithRevoker(r *Revoker) IssuerOption {
+	return func(i *Issuer) {
+		i.revoker = r
+	}
+}
+
+// WithNow sets the clock, time.Now by default.
+func WithNow(now func() time.Time) IssuerOption {
+	return func(i *Issuer) {
+		i.now = now
+	}
+}
+
+// Issuer signs and parses jwt tokens with the keys of a KeySet,
+// the kid header selects the verification key, so the keys can be rotated.
+type Issuer struct {
+	keys       KeySet
+	issuer     string
+	audience   []string
+	ttl        time.Duration
+	maxRefresh time.Duration
+	leeway     time.Duration
+	now        func() time.Time
+	revoker    *Revoker
+}
+
+// NewIssuer creates an issuer.
+func NewIssuer(keys KeySet, opts ...IssuerOption) *Issuer {
+	i := &Issuer{
+		keys:       keys,
+		ttl:        time.Hour,
+		maxRefresh: 7 * 24 * time.Hour,
+		now:        time.Now,
+	}
+	for _, o := range opts {
+		o(i)
+	}
+	return i
+}
+
+// Keys returns the key set.
+func (i *Issuer) Keys() KeySet {
+	return i.keys
+}
+
+// Issue signs the claims, iat, exp, iss and aud are filled if they are empty.
+func (i *Issuer) Issue(claims Claims) (string, error) {
+	key, err := i.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
+		return "", errors.Errorf("unsupported signing algorithm: %s", key.Algorithm)
+	}
+
+	now := i.now()
+	r := claims.Registered()
+	if r.IssuedAt == nil {
+		r.IssuedAt = jwt.NewNumericDate(now)
+	}
+	if r.ExpiresAt == nil && i.ttl > 0 {
+		r.ExpiresAt = jwt.NewNumericDate(now.Add(i.ttl))
+	}
+	if r.Issuer == "" {
+		r.Issuer = i.issuer
+	}
+	if len(r.Audience) == 0 {
+		r.Audience = i.audience
+	}
+	if r.ID == "" {
+		r.ID = uuid.NewString()
+	}
+
+	t := jwt.NewWithClaims(method, claims)
+	if key.ID != "" {
+		t.Header["kid"] = key.ID
+	}
+	return t.SignedString(key.Private)
+}
+
+// Parse verifies the token and validates the claims.
+func (i *Issuer) Parse(tokenString string, claims Claims) error {
+	return i.ParseContext(context.Background(), tokenString, claims)
+}
+
+// ParseContext is Parse with the context passed to the revocation store.
+func (i *Issuer) ParseContext(ctx context.Context, tokenString string, claims Claims) error {
+	if err := i.parse(ctx, tokenString, claims); err != nil {
+		return err
+	}
+	return i.validate(claims.Registered(), i.now())
+}
+
+// Refresh issues a new token with the claims of an unexpired or expired token,
+// as long as it was issued within the max refresh duration.
+func (i *Issuer) Refresh(tokenString string, claims Claims) (string, error) {
+	if err := i.parse(context.Background(), tokenString, claims); err != nil {
+		return "", err
+	}
+	now := i.now()
+	r := claims.Registered()
+	if err := i.validate(r, now); err != nil && !errors.Is(err, ErrTokenExpired) {
+		return "", err
+	}
+	if r.IssuedAt == nil || now.Sub(r.IssuedAt.Time) > i.maxRefresh {
+		return "", ErrRefreshExpired
+	}
+	r.ID, r.IssuedAt, r.ExpiresAt, r.NotBefore = "", nil, nil, nil
+	return i.Issue(claims)
+}
+
+func (i *Issuer) parse(ctx context.Context, tokenString string, claims Claims) error {
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	t, err := parser.ParseWithClaims(tokenString, claims, i.keyFunc)
+	if err == nil {
+		return i.checkRevoked(ctx, t, claims.Registered())
+	}
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) {
+		if ve.Errors&jwt.ValidationErrorMalformed != 0 {
+			return ErrTokenMalformed
+		}
+		if ve.Inner != nil && (errors.Is(ve.Inner, ErrKeyNotFound) || errors.Is(ve.Inner, ErrNoSigningKey)) {
+			return ve.Inner
+		}
+	}
+	return ErrTokenInvalid
+}
+
+func (i *Issuer) checkRevoked(ctx context.Context, t *jwt.Token, r *jwt.RegisteredClaims) error {
+	if i.revoker == nil {
+		return nil
+	}
+	revoked := Token{ID: r.ID, User: r.Subject}
+	revoked.KeyID, _ = t.Header["kid"].(string)
+	if r.IssuedAt != nil {
+		revoked.IssuedAt = r.IssuedAt.Time
+	}
+	return i.revoker.Check(ctx, revoked)
+}
+
+// keyFunc selects the key by kid and rejects the tokens signed by other algorithms.
+func (i *Issuer) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, err := i.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, errors.Errorf("unexpected signing algorithm: %s", t.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+func (i *Issuer) validate(r *jwt.RegisteredClaims, now time.Time) error {
+	if !r.VerifyExpiresAt(now.Add(-i.leeway), false) {
+		return ErrTokenExpired
+	}
+	if !r.VerifyNotBefore(now.Add(i.leeway), false) {
+		return ErrTokenNotValidYet
+	}
+	if i.issuer != "" && !r.VerifyIssuer(i.issuer, true) {
+		return ErrTokenInvalid
+	}
+	for _, aud := range i.audience {
+		if r.VerifyAudience(aud, true) {
+			return nil
+		}
+	}
+	if len(i.audience) > 0 {
+		return ErrTokenInvalid
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+type testClaims struct {
+	StandardClaims
+	Name string `json:"name"`
+}
+
+func newTestKey(t *testing.T, id string, private crypto.Signer, err error) *Key {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKey(id, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func newRSAKey(t *testing.T, id string) *Key {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	return newTestKey(t, id, private, err)
+}
+
+func newECKey(t *testing.T, id string, curve elliptic.Curve) *Key {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	return newTestKey(t, id, private, err)
+}
+
+func newEdKey(t *testing.T, id string) *Key {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	return newTestKey(t, id, private, err)
+}
+
+func issue(t *testing.T, i *Issuer, name string) string {
+	t.Helper()
+	claims := &testClaims{Name: name}
+	claims.Subject = name
+	token, err := i.Issue(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestIssuerRoundTrip(t *testing.T) {
+	tests := []struct {
+		key *Key
+		alg string
+	}{
+		{newRSAKey(t, "rsa"), "RS256"},
+		{newECKey(t, "p256", elliptic.P256()), "ES256"},
+		{newECKey(t, "p384", elliptic.P384()), "ES384"},
+		{newECKey(t, "p521", elliptic.P521()), "ES512"},
+		{newEdKey(t, "ed25519"), "EdDSA"},
+		{NewHMACKey("hmac", []byte("s3cret")), "HS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			keys := NewMemoryKeySet(tt.key)
+			i := NewIssuer(keys, WithIssuer("chaos"), WithAudience("api"))
+			token := issue(t, i, "alice")
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &testClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Method.Alg() != tt.alg || parsed.Header["kid"] != tt.key.ID {
+				t.Errorf("header = %v, want alg %s and kid %s", parsed.Header, tt.alg, tt.key.ID)
+			}
+
+			var claims testClaims
+			if err := i.Parse(token, &claims); err != nil {
+				t.Fatal(err)
+			}
+			if claims.Name != "alice" || claims.Issuer != "chaos" || claims.ID == "" ||
+				claims.ExpiresAt.Sub(claims.IssuedAt.Time) != time.Hour {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			// 对端只用 JWKS 的公钥验证, HMAC 的密钥不公开
+			srv := httptest.NewServer(keys)
+			defer srv.Close()
+			remote := NewIssuer(NewRemoteKeySet(srv.URL, WithRefresh(time.Hour, 0)), WithIssuer("chaos"), WithAudience("api"))
+			err = remote.Parse(token, &testClaims{})
+			if tt.alg == "HS256" {
+				if !errors.Is(err, ErrKeyNotFound) {
+					t.Errorf("remote parse of the hmac token = %v, want ErrKeyNotFound", err)
+				}
+			} else if err != nil {
+				t.Errorf("remote parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestIssuerRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	i := NewIssuer(NewMemoryKeySet(rsaKey))
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	other := newECKey(t, "ec", elliptic.P256())
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		claims := &testClaims{Name: "mallory"}
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// 用 RSA 公钥作为 HMAC 密钥签名
+		{"hs256 with the rsa public key", sign(jwt.SigningMethodHS256, "rsa", publicPEM)},
+		{"hs256 with the rsa modulus", sign(jwt.SigningMethodHS256, "rsa", rsaKey.Public.(*rsa.PublicKey).N.Bytes())},
+		{"es256 for the rsa kid", sign(jwt.SigningMethodES256, "rsa", other.Private)},
+		{"none", sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType)},
+		{"unknown kid", sign(jwt.SigningMethodES256, "ec", other.Private)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := i.Parse(tt.token, &testClaims{}); err == nil {
+				t.Fatal("the token should be rejected")
+			}
+		})
+	}
+
+	// 篡改 payload 的签名不再有效
+	parts := strings.Split(issue(t, i, "alice"), ".")
+	forged := issue(t, NewIssuer(NewMemoryKeySet(newRSAKey(t, "rsa"))), "admin")
+	parts[1] = strings.Split(forged, ".")[1]
+	if err := i.Parse(strings.Join(parts, "."), &testClaims{}); err != ErrTokenInvalid {
+		t.Errorf("tampered payload = %v, want ErrTokenInvalid", err)
+	}
+	if err := i.Parse("not.a.token", &testClaims{}); err != ErrTokenMalformed {
+		t.Errorf("malformed = %v, want ErrTokenMalformed", err)
+	}
+}
+
+func TestIssuerValidatesClaims(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := now
+	keys := NewMemoryKeySet(newEdKey(t, "k1"))
+	at := WithNow(func() time.Time { return clock })
+	i := NewIssuer(keys, at, WithLeeway(time.Minute), WithIssuer("chaos"), WithAudience("api", "web"))
+	token := issue(t, i, "alice")
+
+	tests := []struct {
+		name   string
+		at     time.Duration
+		issuer *Issuer
+		err    error
+	}{
+		{"valid", 30 * time.Minute, i, nil},
+		{"within leeway", time.Hour + 30*time.Second, i, nil},
+		{"expired", time.Hour + 2*time.Minute, i, ErrTokenExpired},
+		{"other issuer", 0, NewIssuer(keys, at, WithIssuer("other")), ErrTokenInvalid},
+		{"other audience", 0, NewIssuer(keys, at, WithAudience("admin")), ErrTokenInvalid},
+		{"one of the audiences", 0, NewIssuer(keys, at, WithAudience("admin", "web")), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock = now.Add(tt.at)
+			if err := tt.issuer.Parse(token, &testClaims{}); err != tt.err {
+				t.Errorf("Parse = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	clock = now
+	claims := &testClaims{}
+	claims.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Minute))
+	early, err := i.Issue(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Parse(early, &testClaims{}); err != ErrTokenNotValidYet {
+		t.Errorf("Parse before nbf = %v, want ErrTokenNotValidYet", err)
+	}
+}
+
+func TestIssuerRefresh(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := now
+	i := NewIssuer(NewMemoryKeySet(newECKey(t, "k1", elliptic.P256())),
+		WithNow(func() time.Time { return clock }), WithTTL(time.Hour), WithMaxRefresh(24*time.Hour))
+	token := issue(t, i, "alice")
+	var old testClaims
+	if err := i.Parse(token, &old); err != nil {
+		t.Fatal(err)
+	}
+
+	// 过期的 token 在 max refresh 内可以刷新
+	clock = now.Add(2 * time.Hour)
+	if err := i.Parse(token, &testClaims{}); err != ErrTokenExpired {
+		t.Fatalf("Parse = %v, want ErrTokenExpired", err)
+	}
+	refreshed, err := i.Refresh(token, &testClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims testClaims
+	if err := i.Parse(refreshed, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Name != "alice" || claims.ID == old.ID || !claims.IssuedAt.Time.Equal(clock) ||
+		!claims.ExpiresAt.Time.Equal(clock.Add(time.Hour)) {
+		t.Errorf("refreshed claims = %+v, old = %+v", claims, old)
+	}
+
+	clock = now.Add(25 * time.Hour)
+	if _, err := i.Refresh(token, &testClaims{}); err != ErrRefreshExpired {
+		t.Errorf("Refresh = %v, want ErrRefreshExpired", err)
+	}
+	other := NewIssuer(NewMemoryKeySet(newECKey(t, "k1", elliptic.P256())), WithNow(func() time.Time { return now }))
+	if _, err := i.Refresh(issue(t, other, "alice"), &testClaims{}); err != ErrTokenInvalid {
+		t.Errorf("Refresh of a token signed by another key = %v, want ErrTokenInvalid", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := NewMemoryKeySet(newRSAKey(t, "k1"))
+	i := NewIssuer(keys)
+	srv := httptest.NewServer(keys)
+	defer srv.Close()
+	remote := NewIssuer(NewRemoteKeySet(srv.URL, WithRefresh(50*time.Millisecond, 0)))
+
+	old := issue(t, i, "alice")
+	if err := remote.Parse(old, &testClaims{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的 token 用新的 key 签名, 旧的 token 仍然可以验证
+	keys.Rotate(newECKey(t, "k2", elliptic.P256()))
+	rotated := issue(t, i, "alice")
+	for _, iss := range []*Issuer{i, remote} {
+		if err := iss.Parse(rotated, &testClaims{}); err != nil {
+			t.Errorf("rotated token: %v", err)
+		}
+		if err := iss.Parse(old, &testClaims{}); err != nil {
+			t.Errorf("old token: %v", err)
+		}
+	}
+
+	// 移除旧的 key 后, 对端在 JWKS 刷新后拒绝旧的 token
+	keys.Remove("k1")
+	if err := i.Parse(old, &testClaims{}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("local parse of the old token = %v, want ErrKeyNotFound", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := remote.Parse(old, &testClaims{})
+		if errors.Is(err, ErrKeyNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("remote parse of the old token = %v, want ErrKeyNotFound", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := remote.Parse(rotated, &testClaims{}); err != nil {
+		t.Errorf("rotated token after the refresh: %v", err)
+	}
+}
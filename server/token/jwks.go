@@ -0,0 +1,283 @@
+package token
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/chaos-ma/chaos/errors"
+	"github.com/chaos-ma/chaos/log"
+)
+
+// JWKSPath is the well-known path to serve the JWKS.
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWK is a public key in the json web key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a json web key set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys, the HMAC keys are never exposed.
+func (s *MemoryKeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range s.Keys() {
+		if jwk, ok := toJWK(k); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// ServeHTTP serves the JWKS, mount it on JWKSPath, e.g. r.GET(token.JWKSPath, gin.WrapH(keys)).
+func (s *MemoryKeySet) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(s.JWKS())
+}
+
+func toJWK(k *Key) (JWK, bool) {
+	enc := base64.RawURLEncoding
+	jwk := JWK{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = enc.EncodeToString(pub.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = enc.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = enc.EncodeToString(pub)
+	default:
+		return jwk, false
+	}
+	return jwk, true
+}
+
+// Key converts the JWK into a verification key.
+func (j JWK) Key() (*Key, error) {
+	dec := base64.RawURLEncoding
+	switch j.Kty {
+	case "RSA":
+		n, err := dec.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return NewPublicKey(j.Kid, algorithmOr(j.Alg, jwt.SigningMethodRS256.Alg()), pub), nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported ecdsa curve: %s", j.Crv)
+		}
+		x, err := dec.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		alg, err := ecdsaAlgorithm(curve)
+		if err != nil {
+			return nil, err
+		}
+		return NewPublicKey(j.Kid, algorithmOr(j.Alg, alg), pub), nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported okp curve: %s", j.Crv)
+		}
+		x, err := dec.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key size")
+		}
+		return NewPublicKey(j.Kid, jwt.SigningMethodEdDSA.Alg(), ed25519.PublicKey(x)), nil
+	}
+	return nil, errors.Errorf("unsupported key type: %s", j.Kty)
+}
+
+func algorithmOr(alg, def string) string {
+	if alg != "" {
+		return alg
+	}
+	return def
+}
+
+// RemoteKeySetOption is remote key set option.
+type RemoteKeySetOption func(s *RemoteKeySet)
+
+// WithHTTPClient sets the http client to fetch the JWKS.
+func WithHTTPClient(client *http.Client) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		s.client = client
+	}
+}
+
+// WithRefresh sets the interval to refresh the JWKS, and the min interval
+// between two fetches caused by unknown kids.
+func WithRefresh(interval, minInterval time.Duration) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		s.interval = interval
+		s.minInterval = minInterval
+	}
+}
+
+// RemoteKeySet verifies tokens with the JWKS served by a peer, so no secret is shared.
+type RemoteKeySet struct {
+	url         string
+	client      *http.Client
+	interval    time.Duration
+	minInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]*Key
+	fetchedAt time.Time
+	// group 合并并发的拉取, 拉取时不持有 mu
+	group singleflight.Group
+}
+
+var _ KeySet = (*RemoteKeySet)(nil)
+
+// NewRemoteKeySet creates a key set which fetches the JWKS from url.
+func NewRemoteKeySet(url string, opts ...RemoteKeySetOption) *RemoteKeySet {
+	s := &RemoteKeySet{
+		url:         url,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		interval:    10 * time.Minute,
+		minInterval: 10 * time.Second,
+		keys:        make(map[string]*Key),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// SigningKey always fails, the remote key set only verifies tokens.
+func (s *RemoteKeySet) SigningKey() (*Key, error) {
+	return nil, ErrNoSigningKey
+}
+
+// VerificationKey returns the key of kid, the JWKS is fetched again if it is stale or the kid is unknown,
+// a known kid is returned at once and the stale JWKS is refreshed in the background.
+func (s *RemoteKeySet) VerificationKey(kid string) (*Key, error) {
+	s.mu.RLock()
+	k, ok := s.keys[kid]
+	elapsed := time.Since(s.fetchedAt)
+	s.mu.RUnlock()
+
+	if ok {
+		if elapsed >= s.interval {
+			s.group.DoChan(s.url, s.refresh)
+		}
+		return k, nil
+	}
+	if elapsed < s.minInterval {
+		return nil, ErrKeyNotFound
+	}
+
+	if _, err, _ := s.group.Do(s.url, s.refresh); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	k, ok = s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+// refresh fetches the JWKS and replaces the keys, the keys are kept if it fails.
+func (s *RemoteKeySet) refresh() (interface{}, error) {
+	s.mu.Lock()
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch()
+	if err != nil {
+		log.Errorf("[token] failed to fetch jwks from %s: %s", s.url, err)
+		return nil, err
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil, nil
+}
+
+// fetch fetches the JWKS without holding the lock.
+func (s *RemoteKeySet) fetch() (map[string]*Key, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		k, err := jwk.Key()
+		if err != nil {
+			log.Warnf("[token] skip jwk %s: %s", jwk.Kid, err)
+			continue
+		}
+		keys[k.ID] = k
+	}
+	return keys, nil
+}
@@ -0,0 +1,75 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteKeySetFetchesOutsideTheLock(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKey("k1", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewMemoryKeySet(k)
+
+	var fetches int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次之后的拉取阻塞到测试结束
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		keys.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := NewRemoteKeySet(srv.URL, WithRefresh(50*time.Millisecond, 0))
+	if _, err := s.VerificationKey("k1"); err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	// 未知 kid 的请求等待阻塞的拉取, 并发的请求只拉取一次
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.VerificationKey("unknown")
+		}()
+	}
+
+	// 已知的 kid 不等待拉取
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.VerificationKey("k1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("known kid: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the known kid is blocked by the fetch")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+	release <- struct{}{}
+	wg.Wait()
+}
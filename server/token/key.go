@@ -0,0 +1,159 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// Defined errors.
+var (
+	ErrKeyNotFound  = errors.New("token key not found")
+	ErrNoSigningKey = errors.New("no signing key")
+)
+
+// Key is a signing or verification key identified by kid.
+type Key struct {
+	ID        string
+	Algorithm string
+	// Private signs tokens, []byte for HMAC, crypto.Signer for RSA, ECDSA and EdDSA.
+	Private interface{}
+	// Public verifies tokens, []byte for HMAC, crypto.PublicKey for the others.
+	Public interface{}
+}
+
+// NewHMACKey creates a HS256 key.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: jwt.SigningMethodHS256.Alg(), Private: secret, Public: secret}
+}
+
+// NewKey creates a signing key, the algorithm is RS256, ES256/ES384/ES512 or EdDSA by the key type.
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	var alg string
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		alg = jwt.SigningMethodRS256.Alg()
+	case *ecdsa.PrivateKey:
+		var err error
+		if alg, err = ecdsaAlgorithm(k.Curve); err != nil {
+			return nil, err
+		}
+	case ed25519.PrivateKey:
+		alg = jwt.SigningMethodEdDSA.Alg()
+	default:
+		return nil, errors.Errorf("unsupported key type: %T", private)
+	}
+	return &Key{ID: id, Algorithm: alg, Private: private, Public: private.Public()}, nil
+}
+
+// NewPublicKey creates a verification only key.
+func NewPublicKey(id string, alg string, public crypto.PublicKey) *Key {
+	return &Key{ID: id, Algorithm: alg, Public: public}
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256.Alg(), nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384.Alg(), nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512.Alg(), nil
+	}
+	return "", errors.Errorf("unsupported ecdsa curve: %s", curve.Params().Name)
+}
+
+// KeySet provides the keys to sign and verify tokens.
+type KeySet interface {
+	// SigningKey returns the current key to sign tokens.
+	SigningKey() (*Key, error)
+	// VerificationKey returns the key of kid, empty kid means the signing key.
+	VerificationKey(kid string) (*Key, error)
+}
+
+// MemoryKeySet is a KeySet which rotates keys in memory,
+// the rotated keys are still used to verify the tokens signed before.
+type MemoryKeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	primary string
+}
+
+var _ KeySet = (*MemoryKeySet)(nil)
+
+// NewMemoryKeySet creates a key set, the first key is the signing key.
+func NewMemoryKeySet(keys ...*Key) *MemoryKeySet {
+	s := &MemoryKeySet{keys: make(map[string]*Key)}
+	for i := len(keys) - 1; i >= 0; i-- {
+		s.Rotate(keys[i])
+	}
+	return s
+}
+
+// Rotate adds the key and signs new tokens with it.
+func (s *MemoryKeySet) Rotate(k *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+	s.primary = k.ID
+}
+
+// Add adds a verification key.
+func (s *MemoryKeySet) Add(k *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+}
+
+// Remove removes the key, the tokens signed by it can not be verified any more.
+func (s *MemoryKeySet) Remove(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, kid)
+	if s.primary == kid {
+		s.primary = ""
+	}
+}
+
+// Keys returns all keys ordered by kid.
+func (s *MemoryKeySet) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+func (s *MemoryKeySet) SigningKey() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[s.primary]
+	if !ok || k.Private == nil {
+		return nil, ErrNoSigningKey
+	}
+	return k, nil
+}
+
+func (s *MemoryKeySet) VerificationKey(kid string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" {
+		kid = s.primary
+	}
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return k, nil
+}
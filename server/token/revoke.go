@@ -0,0 +1,250 @@
+package token
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chaos-ma/chaos/errors"
+)
+
+// ErrTokenRevoked is returned when the token, its user or its key has been revoked.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationStore stores the revoked keys, the key is prefixed by the kind,
+// e.g. "jti:<id>", "user:<username>", "kid:<kid>".
+type RevocationStore interface {
+	// Set records the key as revoked at `at`, the record expires after ttl, zero ttl never expires.
+	Set(ctx context.Context, key string, at time.Time, ttl time.Duration) error
+	// Get returns when the key was revoked.
+	Get(ctx context.Context, key string) (at time.Time, ok bool, err error)
+}
+
+// KV is the minimal interface of Redis-like stores, wrap a redis client into it to share the revocations between instances.
+type KV interface {
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Get returns ok false if the key does not exist.
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+}
+
+type kvStore struct {
+	kv     KV
+	prefix string
+}
+
+// NewKVRevocationStore creates a revocation store on kv, the keys are prefixed by prefix.
+func NewKVRevocationStore(kv KV, prefix string) RevocationStore {
+	return &kvStore{kv: kv, prefix: prefix}
+}
+
+func (s *kvStore) Set(ctx context.Context, key string, at time.Time, ttl time.Duration) error {
+	return s.kv.Set(ctx, s.prefix+key, strconv.FormatInt(at.Unix(), 10), ttl)
+}
+
+func (s *kvStore) Get(ctx context.Context, key string) (time.Time, bool, error) {
+	value, ok, err := s.kv.Get(ctx, s.prefix+key)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "invalid revocation of %s", key)
+	}
+	return time.Unix(sec, 0), true, nil
+}
+
+type memoryEntry struct {
+	at      time.Time
+	expires time.Time
+}
+
+// MemoryRevocationStore is an in-memory revocation store, it is not shared between instances.
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+// NewMemoryRevocationStore creates an in-memory revocation store.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryRevocationStore) Set(_ context.Context, key string, at time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	// 顺便清理过期的记录
+	for k, e := range s.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	e := memoryEntry{at: at}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	s.entries[key] = e
+	return nil
+}
+
+func (s *MemoryRevocationStore) Get(_ context.Context, key string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return time.Time{}, false, nil
+	}
+	return e.at, true, nil
+}
+
+// RevokerOption is revoker option.
+type RevokerOption func(r *Revoker)
+
+// WithCacheTTL caches the lookups locally for ttl, so the revocations of
+// other instances take effect after at most ttl, 5s by default, zero disables the cache.
+func WithCacheTTL(ttl time.Duration) RevokerOption {
+	return func(r *Revoker) {
+		r.cacheTTL = ttl
+	}
+}
+
+// WithSessionTTL sets how long the user and key revocations are kept,
+// it should be longer than the max refresh duration of tokens, 7 days by default.
+func WithSessionTTL(ttl time.Duration) RevokerOption {
+	return func(r *Revoker) {
+		r.sessionTTL = ttl
+	}
+}
+
+// Token is what a revocation check needs to know about a token.
+type Token struct {
+	ID       string
+	User     string
+	KeyID    string
+	IssuedAt time.Time
+}
+
+type cacheEntry struct {
+	at      time.Time
+	ok      bool
+	expires time.Time
+}
+
+// Revoker revokes single tokens, all sessions of a user or all tokens signed by a key.
+type Revoker struct {
+	store      RevocationStore
+	cacheTTL   time.Duration
+	sessionTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewRevoker creates a revoker on store.
+func NewRevoker(store RevocationStore, opts ...RevokerOption) *Revoker {
+	r := &Revoker{
+		store:      store,
+		cacheTTL:   5 * time.Second,
+		sessionTTL: 7 * 24 * time.Hour,
+		cache:      make(map[string]cacheEntry),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// RevokeToken revokes the token of jti, the record is kept until the token expires.
+func (r *Revoker) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return errors.New("token without jti can not be revoked")
+	}
+	ttl := r.sessionTTL
+	if !expiresAt.IsZero() {
+		ttl = time.Until(expiresAt)
+		if ttl <= 0 {
+			return nil
+		}
+	}
+	return r.revoke(ctx, "jti:"+jti, ttl)
+}
+
+// RevokeUser revokes all tokens of the user issued until now, the user can log in again.
+func (r *Revoker) RevokeUser(ctx context.Context, username string) error {
+	return r.revoke(ctx, "user:"+username, r.sessionTTL)
+}
+
+// RevokeKey revokes all tokens signed by kid.
+func (r *Revoker) RevokeKey(ctx context.Context, kid string) error {
+	return r.revoke(ctx, "kid:"+kid, r.sessionTTL)
+}
+
+func (r *Revoker) revoke(ctx context.Context, key string, ttl time.Duration) error {
+	// iat 只精确到秒, 吊销时间也截断到秒, 同一秒内重新登录签发的 token 有效
+	if err := r.store.Set(ctx, key, time.Now().Truncate(time.Second), ttl); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.cache, key)
+	r.mu.Unlock()
+	return nil
+}
+
+// Check returns ErrTokenRevoked if the token, its user or its key has been revoked.
+func (r *Revoker) Check(ctx context.Context, t Token) error {
+	if t.ID != "" {
+		if _, ok, err := r.lookup(ctx, "jti:"+t.ID); err != nil || ok {
+			return revokedOr(err)
+		}
+	}
+	if t.KeyID != "" {
+		if _, ok, err := r.lookup(ctx, "kid:"+t.KeyID); err != nil || ok {
+			return revokedOr(err)
+		}
+	}
+	if t.User != "" {
+		at, ok, err := r.lookup(ctx, "user:"+t.User)
+		if err != nil {
+			return err
+		}
+		// 吊销之后重新登录签发的 token 仍然有效
+		if ok && (t.IssuedAt.IsZero() || t.IssuedAt.Before(at)) {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+func revokedOr(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrTokenRevoked
+}
+
+func (r *Revoker) lookup(ctx context.Context, key string) (time.Time, bool, error) {
+	now := time.Now()
+	if r.cacheTTL > 0 {
+		r.mu.Lock()
+		e, hit := r.cache[key]
+		r.mu.Unlock()
+		if hit && now.Before(e.expires) {
+			return e.at, e.ok, nil
+		}
+	}
+
+	at, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		return at, ok, err
+	}
+	if r.cacheTTL > 0 {
+		r.mu.Lock()
+		if len(r.cache) > 100000 {
+			r.cache = make(map[string]cacheEntry)
+		}
+		r.cache[key] = cacheEntry{at: at, ok: ok, expires: now.Add(r.cacheTTL)}
+		r.mu.Unlock()
+	}
+	return at, ok, nil
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRevokerCheck(t *testing.T) {
+	ctx := context.Background()
+	r := NewRevoker(NewMemoryRevocationStore(), WithCacheTTL(0))
+	before := time.Now().Add(-time.Minute)
+
+	if err := r.RevokeToken(ctx, "jti-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeKey(ctx, "kid-1"); err != nil {
+		t.Fatal(err)
+	}
+	// 同一秒内重新登录签发的 token
+	relogin := time.Unix(time.Now().Unix(), 0)
+
+	tests := []struct {
+		name    string
+		token   Token
+		revoked bool
+	}{
+		{"revoked jti", Token{ID: "jti-1", IssuedAt: relogin}, true},
+		{"other jti", Token{ID: "jti-2", IssuedAt: before}, false},
+		{"revoked kid", Token{KeyID: "kid-1", IssuedAt: relogin}, true},
+		{"other kid", Token{KeyID: "kid-2", IssuedAt: before}, false},
+		{"user token issued before", Token{User: "alice", IssuedAt: before}, true},
+		{"user token without iat", Token{User: "alice"}, true},
+		{"user logs in again in the same second", Token{User: "alice", IssuedAt: relogin}, false},
+		{"user logs in again later", Token{User: "alice", IssuedAt: relogin.Add(time.Second)}, false},
+		{"other user", Token{User: "bob", IssuedAt: before}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Check(ctx, tt.token)
+			if revoked := errors.Is(err, ErrTokenRevoked); revoked != tt.revoked {
+				t.Errorf("Check(%+v) = %v, want revoked %v", tt.token, err, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestRevokeExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	r := NewRevoker(store)
+	if err := r.RevokeToken(ctx, "jti-1", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Get(ctx, "jti:jti-1"); ok {
+		t.Error("the expired token should not be recorded")
+	}
+	if err := r.RevokeToken(ctx, "", time.Time{}); err == nil {
+		t.Error("the token without jti can not be revoked")
+	}
+}
+
+func TestRevokerCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	local := NewRevoker(store, WithCacheTTL(50*time.Millisecond))
+	other := NewRevoker(store)
+	tok := Token{ID: "jti-1"}
+
+	if err := local.Check(ctx, tok); err != nil {
+		t.Fatal(err)
+	}
+	// 另一个实例的吊销在本地缓存过期后生效
+	if err := other.RevokeToken(ctx, "jti-1", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Check(ctx, tok); err != nil {
+		t.Errorf("the cached result should be used within the ttl, got %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := local.Check(ctx, tok); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Check after the ttl = %v, want ErrTokenRevoked", err)
+	}
+
+	// 本实例的吊销立即生效
+	if err := local.Check(ctx, Token{ID: "jti-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.RevokeToken(ctx, "jti-2", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Check(ctx, Token{ID: "jti-2"}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Check after the local revocation = %v, want ErrTokenRevoked", err)
+	}
+}
+
+type mapKV map[string]string
+
+func (kv mapKV) Set(_ context.Context, key, value string, _ time.Duration) error {
+	kv[key] = value
+	return nil
+}
+
+func (kv mapKV) Get(_ context.Context, key string) (string, bool, error) {
+	v, ok := kv[key]
+	return v, ok, nil
+}
+
+func TestKVRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	kv := mapKV{}
+	r := NewRevoker(NewKVRevocationStore(kv, "revoked:"), WithCacheTTL(0))
+	if err := r.RevokeUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv["revoked:user:alice"]; !ok {
+		t.Fatalf("keys = %v, want the prefixed key", kv)
+	}
+	if err := r.Check(ctx, Token{User: "alice", IssuedAt: time.Now().Add(-time.Minute)}); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Check = %v, want ErrTokenRevoked", err)
+	}
+}